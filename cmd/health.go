@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/informer"
+	"github.com/valyala/fasthttp"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// healthCheck is a named probe condition; check returns nil when it holds.
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// healthChecks are evaluated by the /healthz and /readyz endpoints.
+type healthChecks struct {
+	liveness  []healthCheck
+	readiness []healthCheck
+}
+
+// healthResponse is the body of the probe endpoints.
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// writeHealth runs the checks and responds 200 when all pass, 503 otherwise.
+func writeHealth(ctx *fasthttp.RequestCtx, checks []healthCheck) {
+	resp := healthResponse{Status: "ok", Checks: map[string]string{}}
+	status := fasthttp.StatusOK
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			resp.Status = "failed"
+			resp.Checks[c.name] = err.Error()
+			status = fasthttp.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.name] = "ok"
+	}
+	writeJSON(ctx, status, resp)
+}
+
+// managerStatus is added to the controller-runtime manager to learn when it
+// has started, which happens after its caches have synced and before leader
+// election is won. It does not need leader election itself, so standby
+// replicas become ready too and can take over without delaying rollouts.
+type managerStatus struct {
+	started atomic.Bool
+}
+
+var _ manager.LeaderElectionRunnable = &managerStatus{}
+
+// Start implements manager.Runnable.
+func (s *managerStatus) Start(ctx context.Context) error {
+	s.started.Store(true)
+	<-ctx.Done()
+	s.started.Store(false)
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (s *managerStatus) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *managerStatus) check() error {
+	if s.started.Load() {
+		return nil
+	}
+	return errors.New("controller-runtime manager has not started")
+}
+
+// serverHealthChecks builds the probes of the server command. The informer is
+// live while it keeps making progress within livenessResyncs of its resync periods.
+func serverHealthChecks(cache *informer.DeploymentCache, status *managerStatus, livenessResyncs int) healthChecks {
+	maxIdle := time.Duration(livenessResyncs) * cache.ResyncPeriod()
+	return healthChecks{
+		liveness: []healthCheck{
+			{name: "informer", check: func() error { return cache.CheckLiveness(maxIdle) }},
+		},
+		readiness: []healthCheck{
+			{name: "informer", check: func() error {
+				if !cache.HasSynced() {
+					return errors.New("deployment informer has not synced")
+				}
+				return nil
+			}},
+			{name: "manager", check: status.check},
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/informer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHandler_HealthEndpoints(t *testing.T) {
+	health := healthChecks{
+		liveness: []healthCheck{
+			{name: "informer", check: func() error { return nil }},
+		},
+		readiness: []healthCheck{
+			{name: "informer", check: func() error { return nil }},
+			{name: "manager", check: func() error { return errors.New("not started") }},
+		},
+	}
+	handler := createHandler(new(MockDeploymentLister), health)
+
+	ctx := doRequest(handler, "/healthz")
+	assert.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+	var live healthResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &live))
+	assert.Equal(t, healthResponse{Status: "ok", Checks: map[string]string{"informer": "ok"}}, live)
+
+	ctx = doRequest(handler, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, ctx.Response.StatusCode())
+	var ready healthResponse
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &ready))
+	assert.Equal(t, "failed", ready.Status)
+	assert.Equal(t, map[string]string{"informer": "ok", "manager": "not started"}, ready.Checks)
+}
+
+func TestManagerStatus(t *testing.T) {
+	status := &managerStatus{}
+	assert.False(t, status.NeedLeaderElection())
+	assert.Error(t, status.check())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- status.Start(ctx) }()
+	require.Eventually(t, func() bool { return status.check() == nil }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Error(t, status.check())
+}
+
+func TestServerHealthChecks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache := informer.NewDeploymentCache(fake.NewClientset(), informer.Options{Namespaces: []string{"default"}})
+	status := &managerStatus{}
+	handler := createHandler(cache, serverHealthChecks(cache, status, 10))
+
+	req := doRequest(handler, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, req.Response.StatusCode())
+	assert.Contains(t, string(req.Response.Body()), "deployment informer has not synced")
+
+	cache.Start(ctx)
+	require.NoError(t, cache.WaitForSync(ctx, 5*time.Second))
+	status.started.Store(true)
+
+	assert.Equal(t, http.StatusOK, doRequest(handler, "/readyz").Response.StatusCode())
+	assert.Equal(t, http.StatusOK, doRequest(handler, "/healthz").Response.StatusCode())
+}
+
+func TestServerHealthChecks_LivenessUsesCacheResyncPeriod(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache := informer.NewDeploymentCache(fake.NewClientset(), informer.Options{
+		Namespaces:   []string{"default"},
+		ResyncPeriod: time.Second,
+	})
+	handler := createHandler(cache, serverHealthChecks(cache, &managerStatus{}, 1))
+	cache.Start(ctx)
+	require.NoError(t, cache.WaitForSync(ctx, 5*time.Second))
+	assert.Equal(t, http.StatusOK, doRequest(handler, "/healthz").Response.StatusCode())
+
+	// An empty namespace makes no progress, so the window of one resync period expires.
+	require.Eventually(t, func() bool {
+		return doRequest(handler, "/healthz").Response.StatusCode() == http.StatusServiceUnavailable
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -7,20 +7,47 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 var kubeconfig string
+var listNamespace string
+var listAllNamespaces bool
+var listSelector string
+var listOutput string
+var listWatch bool
 
 // KubernetesClient определяет интерфейс для работы с кластером
 type KubernetesClient interface {
-	ListDeployments(namespace string) ([]string, error)
+	// ListDeployments возвращает деплойменты namespace (пустая строка - все namespace),
+	// отфильтрованные на стороне API-сервера по opts
+	ListDeployments(namespace string, opts metav1.ListOptions) (*appsv1.DeploymentList, error)
+	// WatchDeployments следит за изменениями начиная с opts.ResourceVersion
+	WatchDeployments(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error)
+	// GetDeployment возвращает деплоймент по имени
+	GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
+	// GetScale и UpdateScale работают с subresource scale деплоймента
+	GetScale(ctx context.Context, namespace, name string) (*autoscalingv1.Scale, error)
+	UpdateScale(ctx context.Context, namespace, name string, scale *autoscalingv1.Scale) (*autoscalingv1.Scale, error)
+	// PatchDeployment применяет patch указанного типа к деплойменту
+	PatchDeployment(ctx context.Context, namespace, name string, pt types.PatchType, data []byte) (*appsv1.Deployment, error)
+	// ListReplicaSets возвращает ReplicaSet в namespace, отфильтрованные по opts
+	ListReplicaSets(ctx context.Context, namespace string, opts metav1.ListOptions) (*appsv1.ReplicaSetList, error)
 }
 
 // DefaultKubernetesClient реализует KubernetesClient с реальным API Kubernetes
@@ -61,18 +88,42 @@ func NewKubernetesClient(kubeconfigPath string) (KubernetesClient, error) {
 }
 
 // ListDeployments получает список деплойментов в указанном namespace
-func (c *DefaultKubernetesClient) ListDeployments(namespace string) ([]string, error) {
-	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
+func (c *DefaultKubernetesClient) ListDeployments(namespace string, opts metav1.ListOptions) (*appsv1.DeploymentList, error) {
+	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(context.Background(), opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list deployments: %w", err)
 	}
+	return deployments, nil
+}
 
-	var names []string
-	for _, deployment := range deployments.Items {
-		names = append(names, deployment.Name)
-	}
+// WatchDeployments открывает watch деплойментов в указанном namespace
+func (c *DefaultKubernetesClient) WatchDeployments(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return c.clientset.AppsV1().Deployments(namespace).Watch(ctx, opts)
+}
+
+// GetDeployment получает деплоймент по имени
+func (c *DefaultKubernetesClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	return c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// GetScale получает subresource scale деплоймента
+func (c *DefaultKubernetesClient) GetScale(ctx context.Context, namespace, name string) (*autoscalingv1.Scale, error) {
+	return c.clientset.AppsV1().Deployments(namespace).GetScale(ctx, name, metav1.GetOptions{})
+}
 
-	return names, nil
+// UpdateScale обновляет subresource scale деплоймента
+func (c *DefaultKubernetesClient) UpdateScale(ctx context.Context, namespace, name string, scale *autoscalingv1.Scale) (*autoscalingv1.Scale, error) {
+	return c.clientset.AppsV1().Deployments(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+}
+
+// PatchDeployment применяет patch к деплойменту
+func (c *DefaultKubernetesClient) PatchDeployment(ctx context.Context, namespace, name string, pt types.PatchType, data []byte) (*appsv1.Deployment, error) {
+	return c.clientset.AppsV1().Deployments(namespace).Patch(ctx, name, pt, data, metav1.PatchOptions{})
+}
+
+// ListReplicaSets получает список ReplicaSet в указанном namespace
+func (c *DefaultKubernetesClient) ListReplicaSets(ctx context.Context, namespace string, opts metav1.ListOptions) (*appsv1.ReplicaSetList, error) {
+	return c.clientset.AppsV1().ReplicaSets(namespace).List(ctx, opts)
 }
 
 // Factory для создания клиентов - можно заменить в тестах
@@ -81,15 +132,55 @@ var clientFactory = NewKubernetesClient
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "List deployments in the default namespace",
+	Short: "List deployments",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		log.Info().Msg("List command started")
-		return runListCommand(kubeconfig, cmd.OutOrStdout())
+		// Ctrl+C завершает --watch без ошибки
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runListCommand(ctx, kubeconfig, listOptions{
+			namespace:     listNamespace,
+			allNamespaces: listAllNamespaces,
+			selector:      listSelector,
+			output:        listOutput,
+			watch:         listWatch,
+		}, cmd.OutOrStdout())
 	},
 }
 
+// listOptions - флаги команды list
+type listOptions struct {
+	namespace     string
+	allNamespaces bool
+	selector      string
+	output        string
+	watch         bool
+}
+
+// listScope возвращает namespace и ListOptions запроса; --all-namespaces имеет приоритет над --namespace
+func (o listOptions) listScope() (string, metav1.ListOptions, error) {
+	namespace := o.namespace
+	if o.allNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+	// Проверяем селектор заранее, чтобы сообщить об ошибке без запроса к кластеру
+	if _, err := labels.Parse(o.selector); err != nil {
+		return "", metav1.ListOptions{}, fmt.Errorf("invalid selector %q: %w", o.selector, err)
+	}
+	return namespace, metav1.ListOptions{LabelSelector: o.selector}, nil
+}
+
 // runListCommand выполняет логику команды list
-func runListCommand(kubeconfigPath string, out io.Writer) error {
+func runListCommand(ctx context.Context, kubeconfigPath string, opts listOptions, out io.Writer) error {
+	namespace, listOpts, err := opts.listScope()
+	if err != nil {
+		return err
+	}
+	printer, err := newDeploymentPrinter(opts.output, namespace)
+	if err != nil {
+		return err
+	}
+
 	// Создаем клиент
 	client, err := clientFactory(kubeconfigPath)
 	if err != nil {
@@ -98,22 +189,25 @@ func runListCommand(kubeconfigPath string, out io.Writer) error {
 	}
 
 	// Получаем список деплойментов
-	deployments, err := client.ListDeployments("default")
+	deployments, err := client.ListDeployments(namespace, listOpts)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to list deployments")
 		return err
 	}
 
 	// Выводим результат
-	fmt.Fprintf(out, "Found %d deployments in 'default' namespace:\n", len(deployments))
-	for _, name := range deployments {
-		fmt.Fprintln(out, "-", name)
+	if opts.watch {
+		return watchDeployments(ctx, client, namespace, listOpts, deployments, printer, out)
 	}
-
-	return nil
+	return printer.PrintList(deployments, out)
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
 	listCmd.Flags().StringVarP(&kubeconfig, "kubeconfig", "k", "", "Path to kubeconfig file")
+	listCmd.Flags().StringVarP(&listNamespace, "namespace", "n", metav1.NamespaceDefault, "Namespace to list deployments in")
+	listCmd.Flags().BoolVarP(&listAllNamespaces, "all-namespaces", "A", false, "List deployments in all namespaces (overrides --namespace)")
+	listCmd.Flags().StringVarP(&listSelector, "selector", "l", "", "Label selector to filter on, e.g. app=nginx,tier!=canary")
+	listCmd.Flags().BoolVarP(&listWatch, "watch", "w", false, "After listing, watch for changes and print them until interrupted")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "Output format: "+strings.Join(outputFormats, ", ")+" (default: a summary)")
 }
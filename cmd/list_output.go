@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// Форматы вывода команды list (флаг --output)
+const (
+	outputTable      = "table"
+	outputWide       = "wide"
+	outputJSON       = "json"
+	outputYAML       = "yaml"
+	outputName       = "name"
+	outputJSONPath   = "jsonpath"
+	outputGoTemplate = "go-template"
+)
+
+// outputFormats перечисляет форматы для справки и сообщений об ошибках
+var outputFormats = []string{outputTable, outputWide, outputJSON, outputYAML, outputName, outputJSONPath + "=...", outputGoTemplate + "=..."}
+
+// deploymentPrinter печатает список деплойментов в выбранном формате.
+// PrintEvent используется в режиме --watch и печатает одно изменение
+type deploymentPrinter interface {
+	PrintList(list *appsv1.DeploymentList, out io.Writer) error
+	PrintEvent(event watch.EventType, d *appsv1.Deployment, out io.Writer) error
+}
+
+// newDeploymentPrinter создает printer для значения --output. Пустое значение
+// сохраняет прежний вывод "Found N deployments"; namespace нужен только ему и
+// таблице, где при metav1.NamespaceAll добавляется колонка NAMESPACE
+func newDeploymentPrinter(output, namespace string) (deploymentPrinter, error) {
+	format, tmpl, _ := strings.Cut(output, "=")
+	switch format {
+	case "":
+		return summaryPrinter{namespace: namespace}, nil
+	case outputTable, outputWide:
+		return &tablePrinter{wide: format == outputWide, withNamespace: namespace == metav1.NamespaceAll, now: time.Now}, nil
+	case outputJSON:
+		return jsonPrinter{}, nil
+	case outputYAML:
+		return yamlPrinter{}, nil
+	case outputName:
+		return namePrinter{}, nil
+	case outputJSONPath:
+		jp := jsonpath.New("output")
+		if err := jp.Parse(tmpl); err != nil {
+			return nil, fmt.Errorf("invalid jsonpath template %q: %w", tmpl, err)
+		}
+		return templatePrinter{execute: jp.Execute}, nil
+	case outputGoTemplate:
+		t, err := template.New("output").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid go-template %q: %w", tmpl, err)
+		}
+		return templatePrinter{execute: t.Execute}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected one of: %s", output, strings.Join(outputFormats, ", "))
+}
+
+// summaryPrinter - вывод по умолчанию, предназначенный для человека
+type summaryPrinter struct {
+	namespace string
+}
+
+func (p summaryPrinter) PrintList(list *appsv1.DeploymentList, out io.Writer) error {
+	if p.namespace == metav1.NamespaceAll {
+		fmt.Fprintf(out, "Found %d deployments in all namespaces:\n", len(list.Items))
+		for _, d := range list.Items {
+			fmt.Fprintf(out, "- %s/%s\n", d.Namespace, d.Name)
+		}
+		return nil
+	}
+	fmt.Fprintf(out, "Found %d deployments in '%s' namespace:\n", len(list.Items), p.namespace)
+	for _, d := range list.Items {
+		fmt.Fprintln(out, "-", d.Name)
+	}
+	return nil
+}
+
+func (p summaryPrinter) PrintEvent(event watch.EventType, d *appsv1.Deployment, out io.Writer) error {
+	name := d.Name
+	if p.namespace == metav1.NamespaceAll {
+		name = d.Namespace + "/" + d.Name
+	}
+	_, err := fmt.Fprintf(out, "%s %s\n", event, name)
+	return err
+}
+
+// tablePrinter выводит колонки как kubectl get deployments; wide добавляет контейнеры и селектор
+type tablePrinter struct {
+	wide          bool
+	withNamespace bool
+	now           func() time.Time
+
+	// Состояние режима --watch: строки печатаются по одной, поэтому ширина
+	// колонок запоминается и только растет
+	widths        []int
+	headerPrinted bool
+}
+
+func (p *tablePrinter) PrintList(list *appsv1.DeploymentList, out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join(p.header(), "\t"))
+	for i := range list.Items {
+		fmt.Fprintln(w, strings.Join(p.row(&list.Items[i]), "\t"))
+	}
+	return w.Flush()
+}
+
+// PrintEvent добавляет колонку EVENT и печатает заголовок перед первой строкой
+func (p *tablePrinter) PrintEvent(event watch.EventType, d *appsv1.Deployment, out io.Writer) error {
+	if !p.headerPrinted {
+		if err := p.printAligned(append([]string{"EVENT"}, p.header()...), out); err != nil {
+			return err
+		}
+		p.headerPrinted = true
+	}
+	return p.printAligned(append([]string{string(event)}, p.row(d)...), out)
+}
+
+func (p *tablePrinter) printAligned(cells []string, out io.Writer) error {
+	var line strings.Builder
+	for i, cell := range cells {
+		if i == len(p.widths) {
+			p.widths = append(p.widths, 0)
+		}
+		p.widths[i] = max(p.widths[i], len(cell))
+		if i == len(cells)-1 {
+			line.WriteString(cell)
+			break
+		}
+		fmt.Fprintf(&line, "%-*s   ", p.widths[i], cell)
+	}
+	_, err := fmt.Fprintln(out, line.String())
+	return err
+}
+
+func (p *tablePrinter) header() []string {
+	var columns []string
+	if p.withNamespace {
+		columns = append(columns, "NAMESPACE")
+	}
+	columns = append(columns, "NAME", "READY", "UP-TO-DATE", "AVAILABLE", "AGE")
+	if p.wide {
+		columns = append(columns, "CONTAINERS")
+	}
+	columns = append(columns, "IMAGES")
+	if p.wide {
+		columns = append(columns, "SELECTOR")
+	}
+	return columns
+}
+
+func (p *tablePrinter) row(d *appsv1.Deployment) []string {
+	desired := int32(1)
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+	var names, images []string
+	for _, c := range d.Spec.Template.Spec.Containers {
+		names = append(names, c.Name)
+		images = append(images, c.Image)
+	}
+
+	var cells []string
+	if p.withNamespace {
+		cells = append(cells, d.Namespace)
+	}
+	cells = append(cells,
+		d.Name,
+		fmt.Sprintf("%d/%d", d.Status.ReadyReplicas, desired),
+		fmt.Sprint(d.Status.UpdatedReplicas),
+		fmt.Sprint(d.Status.AvailableReplicas),
+		p.age(d.CreationTimestamp),
+	)
+	if p.wide {
+		cells = append(cells, strings.Join(names, ","))
+	}
+	cells = append(cells, strings.Join(images, ","))
+	if p.wide {
+		cells = append(cells, metav1.FormatLabelSelector(d.Spec.Selector))
+	}
+	return cells
+}
+
+func (p *tablePrinter) age(created metav1.Time) string {
+	if created.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(p.now().Sub(created.Time))
+}
+
+// jsonPrinter выводит DeploymentList, который можно передать в kubectl apply
+type jsonPrinter struct{}
+
+func (jsonPrinter) PrintList(list *appsv1.DeploymentList, out io.Writer) error {
+	data, err := json.MarshalIndent(typedList(list), "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode deployments as JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+func (jsonPrinter) PrintEvent(event watch.EventType, d *appsv1.Deployment, out io.Writer) error {
+	data, err := json.MarshalIndent(newDeploymentEvent(event, d), "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment event as JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+type yamlPrinter struct{}
+
+func (yamlPrinter) PrintList(list *appsv1.DeploymentList, out io.Writer) error {
+	data, err := yaml.Marshal(typedList(list))
+	if err != nil {
+		return fmt.Errorf("failed to encode deployments as YAML: %w", err)
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// PrintEvent разделяет события маркером документа, чтобы поток оставался валидным YAML
+func (yamlPrinter) PrintEvent(event watch.EventType, d *appsv1.Deployment, out io.Writer) error {
+	data, err := yaml.Marshal(newDeploymentEvent(event, d))
+	if err != nil {
+		return fmt.Errorf("failed to encode deployment event as YAML: %w", err)
+	}
+	_, err = fmt.Fprintf(out, "---\n%s", data)
+	return err
+}
+
+// namePrinter выводит resource/name, как kubectl get -o name
+type namePrinter struct{}
+
+func (namePrinter) PrintList(list *appsv1.DeploymentList, out io.Writer) error {
+	for _, d := range list.Items {
+		fmt.Fprintf(out, "deployment.apps/%s\n", d.Name)
+	}
+	return nil
+}
+
+// PrintEvent печатает только имя, как kubectl get -w -o name
+func (namePrinter) PrintEvent(_ watch.EventType, d *appsv1.Deployment, out io.Writer) error {
+	_, err := fmt.Fprintf(out, "deployment.apps/%s\n", d.Name)
+	return err
+}
+
+// templatePrinter применяет jsonpath или go-template к JSON-представлению списка,
+// поэтому в шаблонах используются имена полей из JSON (.items, .metadata.name)
+type templatePrinter struct {
+	execute func(w io.Writer, data any) error
+}
+
+func (p templatePrinter) PrintList(list *appsv1.DeploymentList, out io.Writer) error {
+	return p.print(typedList(list), out)
+}
+
+// PrintEvent применяет шаблон к событию, например {.type} {.object.metadata.name}
+func (p templatePrinter) PrintEvent(event watch.EventType, d *appsv1.Deployment, out io.Writer) error {
+	return p.print(newDeploymentEvent(event, d), out)
+}
+
+func (p templatePrinter) print(v any, out io.Writer) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode deployments: %w", err)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("failed to decode deployments: %w", err)
+	}
+	if err := p.execute(out, obj); err != nil {
+		return fmt.Errorf("failed to execute output template: %w", err)
+	}
+	return nil
+}
+
+// deploymentEvent повторяет формат событий watch API Kubernetes
+type deploymentEvent struct {
+	Type   watch.EventType    `json:"type"`
+	Object *appsv1.Deployment `json:"object"`
+}
+
+func newDeploymentEvent(event watch.EventType, d *appsv1.Deployment) deploymentEvent {
+	d = d.DeepCopy()
+	d.TypeMeta = metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"}
+	return deploymentEvent{Type: event, Object: d}
+}
+
+// typedList заполняет apiVersion и kind, которые typed-клиент не возвращает
+func typedList(list *appsv1.DeploymentList) *appsv1.DeploymentList {
+	list = list.DeepCopy()
+	list.TypeMeta = metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "DeploymentList"}
+	if list.Items == nil {
+		list.Items = []appsv1.Deployment{}
+	}
+	for i := range list.Items {
+		list.Items[i].TypeMeta = metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"}
+	}
+	return list
+}
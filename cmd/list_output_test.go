@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// testDeploymentList возвращает два деплоймента в разных namespace
+func testDeploymentList(now time.Time) *appsv1.DeploymentList {
+	replicas := int32(3)
+	return &appsv1.DeploymentList{Items: []appsv1.Deployment{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "nginx", Image: "nginx:1.27"},
+					{Name: "proxy", Image: "envoy:1.30"},
+				}}},
+			},
+			Status: appsv1.DeploymentStatus{ReadyReplicas: 2, UpdatedReplicas: 3, AvailableReplicas: 2},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "payments"},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "api", Image: "api:v2"}}}},
+			},
+		},
+	}}
+}
+
+func printList(t *testing.T, output, namespace string, list *appsv1.DeploymentList) string {
+	t.Helper()
+	printer, err := newDeploymentPrinter(output, namespace)
+	require.NoError(t, err)
+	if p, ok := printer.(*tablePrinter); ok {
+		now := list.Items[0].CreationTimestamp.Add(2 * time.Hour)
+		p.now = func() time.Time { return now }
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, printer.PrintList(list, buf))
+	return buf.String()
+}
+
+// fields разбивает вывод таблицы на строки и колонки
+func fields(output string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		rows = append(rows, strings.Fields(line))
+	}
+	return rows
+}
+
+func TestTablePrinter(t *testing.T) {
+	list := testDeploymentList(time.Now())
+
+	assert.Equal(t, [][]string{
+		{"NAME", "READY", "UP-TO-DATE", "AVAILABLE", "AGE", "IMAGES"},
+		{"web", "2/3", "3", "2", "120m", "nginx:1.27,envoy:1.30"},
+		{"api", "0/1", "0", "0", "<unknown>", "api:v2"},
+	}, fields(printList(t, "table", "default", list)))
+
+	assert.Equal(t, [][]string{
+		{"NAMESPACE", "NAME", "READY", "UP-TO-DATE", "AVAILABLE", "AGE", "CONTAINERS", "IMAGES", "SELECTOR"},
+		{"default", "web", "2/3", "3", "2", "120m", "nginx,proxy", "nginx:1.27,envoy:1.30", "app=web"},
+		{"payments", "api", "0/1", "0", "0", "<unknown>", "api", "api:v2", "<none>"},
+	}, fields(printList(t, "wide", metav1.NamespaceAll, list)))
+}
+
+func TestStructuredPrinters(t *testing.T) {
+	list := testDeploymentList(time.Now())
+
+	t.Run("JSON", func(t *testing.T) {
+		var decoded appsv1.DeploymentList
+		require.NoError(t, json.Unmarshal([]byte(printList(t, "json", "default", list)), &decoded))
+		assert.Equal(t, "apps/v1", decoded.APIVersion)
+		assert.Equal(t, "DeploymentList", decoded.Kind)
+		require.Len(t, decoded.Items, 2)
+		assert.Equal(t, "Deployment", decoded.Items[0].Kind)
+		assert.Equal(t, "nginx:1.27", decoded.Items[0].Spec.Template.Spec.Containers[0].Image)
+		assert.Empty(t, list.Kind, "the listed object is not modified")
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		var decoded appsv1.DeploymentList
+		require.NoError(t, yaml.UnmarshalStrict([]byte(printList(t, "yaml", "default", list)), &decoded))
+		assert.Equal(t, "DeploymentList", decoded.Kind)
+		assert.Equal(t, "payments", decoded.Items[1].Namespace)
+	})
+
+	t.Run("Empty list", func(t *testing.T) {
+		assert.Contains(t, printList(t, "json", "default", &appsv1.DeploymentList{}), `"items": []`)
+	})
+
+	t.Run("Name", func(t *testing.T) {
+		assert.Equal(t, "deployment.apps/web\ndeployment.apps/api\n", printList(t, "name", "default", list))
+	})
+
+	t.Run("JSONPath", func(t *testing.T) {
+		output := printList(t, `jsonpath={range .items[*]}{.metadata.namespace}/{.metadata.name}{"\n"}{end}`, "default", list)
+		assert.Equal(t, "default/web\npayments/api\n", output)
+	})
+
+	t.Run("Go template", func(t *testing.T) {
+		output := printList(t, `go-template={{range .items}}{{.metadata.name}} {{.status.readyReplicas}}{{"\n"}}{{end}}`, "default", list)
+		assert.Equal(t, "web 2\napi <no value>\n", output)
+	})
+}
+
+func TestNewDeploymentPrinter_Errors(t *testing.T) {
+	for output, message := range map[string]string{
+		"csv":                 "unknown output format",
+		"jsonpath={.items[":   "invalid jsonpath template",
+		"go-template={{.foo":  "invalid go-template",
+		"templatefile=x.tmpl": "unknown output format",
+	} {
+		_, err := newDeploymentPrinter(output, "default")
+		assert.ErrorContains(t, err, message, output)
+	}
+}
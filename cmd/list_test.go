@@ -2,25 +2,67 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
 // MockKubernetesClient mock для тестирования
 type MockKubernetesClient struct {
 	DeploymentNames []string
 	Error           error
+
+	// Аргументы последнего вызова ListDeployments
+	Namespace   string
+	ListOptions metav1.ListOptions
 }
 
-func (m *MockKubernetesClient) ListDeployments(namespace string) ([]string, error) {
+func (m *MockKubernetesClient) ListDeployments(namespace string, opts metav1.ListOptions) (*appsv1.DeploymentList, error) {
+	m.Namespace, m.ListOptions = namespace, opts
 	if m.Error != nil {
 		return nil, m.Error
 	}
-	return m.DeploymentNames, nil
+	list := &appsv1.DeploymentList{}
+	for _, name := range m.DeploymentNames {
+		list.Items = append(list.Items, appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}})
+	}
+	return list, nil
+}
+
+func (m *MockKubernetesClient) WatchDeployments(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	return nil, fmt.Errorf("watch is not supported by the mock")
+}
+
+func (m *MockKubernetesClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	return nil, fmt.Errorf("get is not supported by the mock")
+}
+
+func (m *MockKubernetesClient) GetScale(ctx context.Context, namespace, name string) (*autoscalingv1.Scale, error) {
+	return nil, fmt.Errorf("scale is not supported by the mock")
+}
+
+func (m *MockKubernetesClient) UpdateScale(ctx context.Context, namespace, name string, scale *autoscalingv1.Scale) (*autoscalingv1.Scale, error) {
+	return nil, fmt.Errorf("scale is not supported by the mock")
+}
+
+func (m *MockKubernetesClient) PatchDeployment(ctx context.Context, namespace, name string, pt types.PatchType, data []byte) (*appsv1.Deployment, error) {
+	return nil, fmt.Errorf("patch is not supported by the mock")
+}
+
+func (m *MockKubernetesClient) ListReplicaSets(ctx context.Context, namespace string, opts metav1.ListOptions) (*appsv1.ReplicaSetList, error) {
+	return nil, fmt.Errorf("replica sets are not supported by the mock")
 }
 
+// defaultListOptions соответствует команде list без флагов
+var defaultListOptions = listOptions{namespace: "default"}
+
 // TestRunListCommand тестирует логику команды list
 func TestRunListCommand(t *testing.T) {
 	// Сохраняем оригинальную фабрику
@@ -44,7 +86,7 @@ func TestRunListCommand(t *testing.T) {
 		buf := new(bytes.Buffer)
 
 		// Вызываем тестируемую функцию
-		err := runListCommand("test-kubeconfig", buf)
+		err := runListCommand(context.Background(), "test-kubeconfig", defaultListOptions, buf)
 
 		// Проверяем результат
 		assert.NoError(t, err)
@@ -52,6 +94,72 @@ func TestRunListCommand(t *testing.T) {
 		assert.Contains(t, output, "Found 2 deployments")
 		assert.Contains(t, output, "test-deployment-1")
 		assert.Contains(t, output, "test-deployment-2")
+		assert.Equal(t, "default", mockClient.Namespace)
+	})
+
+	t.Run("Namespace and selector", func(t *testing.T) {
+		mockClient := &MockKubernetesClient{DeploymentNames: []string{"web"}}
+		clientFactory = func(kubeconfigPath string) (KubernetesClient, error) {
+			return mockClient, nil
+		}
+		buf := new(bytes.Buffer)
+
+		err := runListCommand(context.Background(), "test-kubeconfig", listOptions{namespace: "payments", selector: "app=web"}, buf)
+
+		assert.NoError(t, err)
+		// Фильтрация выполняется на стороне API-сервера
+		assert.Equal(t, "payments", mockClient.Namespace)
+		assert.Equal(t, "app=web", mockClient.ListOptions.LabelSelector)
+		assert.Contains(t, buf.String(), "Found 1 deployments in 'payments' namespace")
+	})
+
+	t.Run("All namespaces", func(t *testing.T) {
+		mockClient := &MockKubernetesClient{DeploymentNames: []string{"web"}}
+		clientFactory = func(kubeconfigPath string) (KubernetesClient, error) {
+			return mockClient, nil
+		}
+		buf := new(bytes.Buffer)
+
+		err := runListCommand(context.Background(), "test-kubeconfig", listOptions{namespace: "payments", allNamespaces: true}, buf)
+
+		assert.NoError(t, err)
+		assert.Equal(t, metav1.NamespaceAll, mockClient.Namespace)
+		assert.Contains(t, buf.String(), "Found 1 deployments in all namespaces")
+		assert.Contains(t, buf.String(), "- default/web")
+	})
+
+	t.Run("Invalid selector", func(t *testing.T) {
+		clientFactory = func(kubeconfigPath string) (KubernetesClient, error) {
+			t.Fatal("the client must not be created for an invalid selector")
+			return nil, nil
+		}
+
+		err := runListCommand(context.Background(), "test-kubeconfig", listOptions{selector: "app in web"}, new(bytes.Buffer))
+
+		assert.ErrorContains(t, err, "invalid selector")
+	})
+
+	t.Run("Output format", func(t *testing.T) {
+		clientFactory = func(kubeconfigPath string) (KubernetesClient, error) {
+			return &MockKubernetesClient{DeploymentNames: []string{"web"}}, nil
+		}
+		buf := new(bytes.Buffer)
+
+		err := runListCommand(context.Background(), "test-kubeconfig", listOptions{namespace: "default", output: "name"}, buf)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "deployment.apps/web\n", buf.String())
+	})
+
+	t.Run("Invalid output format", func(t *testing.T) {
+		clientFactory = func(kubeconfigPath string) (KubernetesClient, error) {
+			t.Fatal("the client must not be created for an invalid output format")
+			return nil, nil
+		}
+
+		err := runListCommand(context.Background(), "test-kubeconfig", listOptions{namespace: "default", output: "csv"}, new(bytes.Buffer))
+
+		assert.ErrorContains(t, err, "unknown output format")
 	})
 
 	t.Run("Client creation error", func(t *testing.T) {
@@ -64,7 +172,7 @@ func TestRunListCommand(t *testing.T) {
 		buf := new(bytes.Buffer)
 
 		// Вызываем тестируемую функцию
-		err := runListCommand("test-kubeconfig", buf)
+		err := runListCommand(context.Background(), "test-kubeconfig", defaultListOptions, buf)
 
 		// Проверяем результат
 		assert.Error(t, err)
@@ -86,7 +194,7 @@ func TestRunListCommand(t *testing.T) {
 		buf := new(bytes.Buffer)
 
 		// Вызываем тестируемую функцию
-		err := runListCommand("test-kubeconfig", buf)
+		err := runListCommand(context.Background(), "test-kubeconfig", defaultListOptions, buf)
 
 		// Проверяем результат
 		assert.Error(t, err)
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchRetryDelay - пауза перед повторным открытием watch после ошибки
+var watchRetryDelay = time.Second
+
+// errWatchExpired означает, что resourceVersion устарел и нужен новый list
+var errWatchExpired = errors.New("watch expired")
+
+// printError - ошибка вывода; в отличие от ошибок watch она завершает команду
+type printError struct {
+	err error
+}
+
+func (e *printError) Error() string { return e.err.Error() }
+func (e *printError) Unwrap() error { return e.err }
+
+// deploymentWatch хранит состояние list --watch между переподключениями
+type deploymentWatch struct {
+	client    KubernetesClient
+	namespace string
+	opts      metav1.ListOptions
+	printer   deploymentPrinter
+	out       io.Writer
+
+	// Последний увиденный resourceVersion и известные деплойменты, по которым
+	// после повторного list вычисляются пропущенные изменения
+	resourceVersion string
+	known           map[types.NamespacedName]*appsv1.Deployment
+}
+
+// watchDeployments печатает начальный список как события ADDED и затем изменения
+// до отмены ctx. Закрытый сервером watch открывается заново с последнего
+// resourceVersion; если он устарел (410 Gone), список запрашивается повторно
+func watchDeployments(ctx context.Context, client KubernetesClient, namespace string, opts metav1.ListOptions,
+	list *appsv1.DeploymentList, printer deploymentPrinter, out io.Writer) error {
+	w := &deploymentWatch{
+		client:    client,
+		namespace: namespace,
+		opts:      opts,
+		printer:   printer,
+		out:       out,
+		known:     map[types.NamespacedName]*appsv1.Deployment{},
+	}
+	if err := w.sync(list); err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		err := w.watch(ctx)
+		if errors.Is(err, errWatchExpired) || apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			log.Debug().Err(err).Msg("Watch expired, listing deployments again")
+			err = w.relist()
+		}
+		switch {
+		case ctx.Err() != nil || err == nil:
+		case errors.As(err, new(*printError)):
+			return err
+		case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+			return fmt.Errorf("failed to watch deployments: %w", err)
+		default:
+			log.Warn().Err(err).Msg("Watch failed, retrying")
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryDelay):
+			}
+		}
+	}
+	return nil
+}
+
+// watch обрабатывает события одного watch. nil означает, что сервер закрыл соединение
+func (w *deploymentWatch) watch(ctx context.Context) error {
+	opts := w.opts
+	opts.ResourceVersion = w.resourceVersion
+	opts.AllowWatchBookmarks = true
+	watcher, err := w.client.WatchDeployments(ctx, w.namespace, opts)
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				log.Debug().Str("resourceVersion", w.resourceVersion).Msg("Watch closed, reconnecting")
+				return nil
+			}
+			if event.Type == watch.Error {
+				status := apierrors.FromObject(event.Object)
+				if apierrors.IsResourceExpired(status) || apierrors.IsGone(status) {
+					return errWatchExpired
+				}
+				return status
+			}
+			d, ok := event.Object.(*appsv1.Deployment)
+			if !ok {
+				log.Warn().Str("type", string(event.Type)).Msgf("Unexpected object in watch: %T", event.Object)
+				continue
+			}
+			w.resourceVersion = d.ResourceVersion
+			if event.Type == watch.Bookmark {
+				continue
+			}
+			if err := w.print(event.Type, d); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sync печатает начальный список
+func (w *deploymentWatch) sync(list *appsv1.DeploymentList) error {
+	w.resourceVersion = list.ResourceVersion
+	for i := range list.Items {
+		if err := w.print(watch.Added, &list.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relist запрашивает список заново и печатает изменения, пропущенные за время
+// истекшего watch, включая удаления
+func (w *deploymentWatch) relist() error {
+	list, err := w.client.ListDeployments(w.namespace, w.opts)
+	if err != nil {
+		return err
+	}
+	w.resourceVersion = list.ResourceVersion
+
+	current := map[types.NamespacedName]bool{}
+	for i := range list.Items {
+		d := &list.Items[i]
+		key := types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
+		current[key] = true
+		switch old, found := w.known[key]; {
+		case !found:
+			err = w.print(watch.Added, d)
+		case old.ResourceVersion != d.ResourceVersion:
+			err = w.print(watch.Modified, d)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for key, d := range w.known {
+		if !current[key] {
+			if err := w.print(watch.Deleted, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *deploymentWatch) print(event watch.EventType, d *appsv1.Deployment) error {
+	key := types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
+	if event == watch.Deleted {
+		delete(w.known, key)
+	} else {
+		w.known[key] = d
+	}
+	if err := w.printer.PrintEvent(event, d, w.out); err != nil {
+		return &printError{err: err}
+	}
+	return nil
+}
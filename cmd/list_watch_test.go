@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// scriptedClient отдает заранее подготовленные списки и watch по очереди
+// и запоминает resourceVersion каждого запроса
+type scriptedClient struct {
+	MockKubernetesClient
+
+	lists   []*appsv1.DeploymentList
+	watches []func() (watch.Interface, error)
+	// done вызывается, когда подготовленные watch закончились
+	done func()
+
+	listVersions  []string
+	watchVersions []string
+}
+
+func (c *scriptedClient) ListDeployments(namespace string, opts metav1.ListOptions) (*appsv1.DeploymentList, error) {
+	c.listVersions = append(c.listVersions, opts.ResourceVersion)
+	list := c.lists[0]
+	c.lists = c.lists[1:]
+	return list, nil
+}
+
+func (c *scriptedClient) WatchDeployments(ctx context.Context, namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	c.watchVersions = append(c.watchVersions, opts.ResourceVersion)
+	if len(c.watches) == 0 {
+		c.done()
+		return nil, ctx.Err()
+	}
+	next := c.watches[0]
+	c.watches = c.watches[1:]
+	return next()
+}
+
+func deployment(name, resourceVersion string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ResourceVersion: resourceVersion}}
+}
+
+// watchEvents возвращает закрытый watch с заданными событиями
+func watchEvents(events ...watch.Event) func() (watch.Interface, error) {
+	return func() (watch.Interface, error) {
+		w := watch.NewFakeWithChanSize(len(events), false)
+		for _, e := range events {
+			w.Action(e.Type, e.Object)
+		}
+		w.Stop()
+		return w, nil
+	}
+}
+
+func TestWatchDeployments(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expired := apierrors.NewResourceExpired("too old resource version: 12 (30)")
+	gvr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+	client := &scriptedClient{
+		lists: []*appsv1.DeploymentList{
+			{ListMeta: metav1.ListMeta{ResourceVersion: "10"}, Items: []appsv1.Deployment{*deployment("web", "9")}},
+			// Повторный list после истечения watch: api удален, db создан
+			{ListMeta: metav1.ListMeta{ResourceVersion: "20"}, Items: []appsv1.Deployment{*deployment("web", "11"), *deployment("db", "19")}},
+		},
+		watches: []func() (watch.Interface, error){
+			watchEvents(
+				watch.Event{Type: watch.Modified, Object: deployment("web", "11")},
+				watch.Event{Type: watch.Added, Object: deployment("api", "12")},
+			),
+			// Сетевая ошибка: watch открывается заново с того же resourceVersion
+			func() (watch.Interface, error) { return nil, fmt.Errorf("connection refused") },
+			watchEvents(watch.Event{Type: watch.Bookmark, Object: deployment("", "13")}),
+			watchEvents(watch.Event{Type: watch.Error, Object: &expired.ErrStatus}),
+			func() (watch.Interface, error) {
+				return nil, apierrors.NewGenericServerResponse(http.StatusInternalServerError, "watch", gvr, "", "etcd unavailable", 0, true)
+			},
+			watchEvents(watch.Event{Type: watch.Deleted, Object: deployment("db", "21")}),
+		},
+		done: cancel,
+	}
+	origDelay := watchRetryDelay
+	watchRetryDelay = 0
+	defer func() { watchRetryDelay = origDelay }()
+
+	list, err := client.ListDeployments("default", metav1.ListOptions{})
+	require.NoError(t, err)
+	printer, err := newDeploymentPrinter(`go-template={{.type}} {{.object.metadata.name}} {{.object.metadata.resourceVersion}}{{"\n"}}`, "default")
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+
+	require.NoError(t, watchDeployments(ctx, client, "default", metav1.ListOptions{}, list, printer, buf))
+
+	assert.Equal(t, "ADDED web 9\n"+
+		"MODIFIED web 11\n"+
+		"ADDED api 12\n"+
+		"ADDED db 19\n"+
+		"DELETED api 12\n"+
+		"DELETED db 21\n", buf.String())
+	assert.Equal(t, []string{"", ""}, client.listVersions, "the relist reads the latest state")
+	assert.Equal(t, []string{"10", "12", "12", "13", "20", "20", "21"}, client.watchVersions)
+}
+
+func TestWatchDeployments_Forbidden(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", fmt.Errorf("no watch permission"))
+	client := &scriptedClient{watches: []func() (watch.Interface, error){
+		func() (watch.Interface, error) { return nil, forbidden },
+	}}
+	printer, err := newDeploymentPrinter("", "default")
+	require.NoError(t, err)
+
+	err = watchDeployments(context.Background(), client, "default", metav1.ListOptions{}, &appsv1.DeploymentList{}, printer, new(bytes.Buffer))
+
+	assert.ErrorContains(t, err, "no watch permission")
+}
+
+func TestTablePrinter_PrintEvent(t *testing.T) {
+	printer, err := newDeploymentPrinter("table", "default")
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+
+	require.NoError(t, printer.PrintEvent(watch.Added, deployment("web", "1"), buf))
+	require.NoError(t, printer.PrintEvent(watch.Deleted, deployment("web", "2"), buf))
+
+	assert.Equal(t, [][]string{
+		{"EVENT", "NAME", "READY", "UP-TO-DATE", "AVAILABLE", "AGE", "IMAGES"},
+		{"ADDED", "web", "0/1", "0", "0", "<unknown>"},
+		{"DELETED", "web", "0/1", "0", "0", "<unknown>"},
+	}, fields(buf.String()))
+}
+
+func TestStructuredPrinters_PrintEvent(t *testing.T) {
+	d := deployment("web", "1")
+
+	jsonOut, err := newDeploymentPrinter("json", "default")
+	require.NoError(t, err)
+	buf := new(bytes.Buffer)
+	require.NoError(t, jsonOut.PrintEvent(watch.Modified, d, buf))
+	assert.Contains(t, buf.String(), `"type": "MODIFIED"`)
+	assert.Contains(t, buf.String(), `"kind": "Deployment"`)
+
+	yamlOut, err := newDeploymentPrinter("yaml", "default")
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, yamlOut.PrintEvent(watch.Added, d, buf))
+	require.NoError(t, yamlOut.PrintEvent(watch.Deleted, d, buf))
+	assert.Contains(t, buf.String(), "---\nobject:\n")
+	assert.Contains(t, buf.String(), "type: DELETED\n")
+
+	summary, err := newDeploymentPrinter("", metav1.NamespaceAll)
+	require.NoError(t, err)
+	buf.Reset()
+	require.NoError(t, summary.PrintEvent(watch.Added, d, buf))
+	assert.Equal(t, "ADDED default/web\n", buf.String())
+}
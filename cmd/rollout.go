@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// restartedAtAnnotation - аннотация шаблона пода, которую выставляет kubectl rollout restart
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// progressDeadlineExceeded - reason условия Progressing, когда rollout завис
+const progressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+var rolloutNamespace string
+var rolloutTimeout time.Duration
+var rolloutHistoryRevision int64
+var rolloutUndoRevision int64
+
+// rolloutStatusInterval - период опроса деплоймента в rollout status
+var rolloutStatusInterval = time.Second
+
+// rolloutOptions - аргументы и флаги подкоманд rollout
+type rolloutOptions struct {
+	namespace string
+	name      string
+	// timeout ограничивает ожидание в status; 0 - без ограничения
+	timeout time.Duration
+	// revision выбирает ревизию в history и undo; 0 - все ревизии или предыдущая
+	revision int64
+}
+
+// rolloutAction - действие подкоманды rollout над деплойментом
+type rolloutAction func(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error
+
+// rolloutCmd represents the rollout command
+var rolloutCmd = &cobra.Command{
+	Use:   "rollout",
+	Short: "Manage the rollout of a deployment",
+}
+
+var rolloutStatusCmd = newRolloutCommand("status NAME", "Wait until a deployment rollout completes", rolloutStatus, nil)
+var rolloutRestartCmd = newRolloutCommand("restart NAME", "Restart the pods of a deployment", rolloutRestart, nil)
+var rolloutPauseCmd = newRolloutCommand("pause NAME", "Pause the rollout of a deployment", rolloutPause, nil)
+var rolloutResumeCmd = newRolloutCommand("resume NAME", "Resume a paused deployment", rolloutResume, nil)
+var rolloutHistoryCmd = newRolloutCommand("history NAME", "Show the revisions of a deployment", rolloutHistory, &rolloutHistoryRevision)
+var rolloutUndoCmd = newRolloutCommand("undo NAME", "Roll a deployment back to a previous revision", rolloutUndo, &rolloutUndoRevision)
+
+// newRolloutCommand создает подкоманду; revision - переменная ее флага ревизии
+// или nil, если у подкоманды его нет
+func newRolloutCommand(use, short string, action rolloutAction, revision *int64) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			opts := rolloutOptions{
+				namespace: rolloutNamespace,
+				name:      args[0],
+				timeout:   rolloutTimeout,
+			}
+			if revision != nil {
+				opts.revision = *revision
+			}
+			return runRolloutCommand(ctx, kubeconfig, opts, action, cmd.OutOrStdout())
+		},
+	}
+}
+
+// runRolloutCommand создает клиент и выполняет действие подкоманды
+func runRolloutCommand(ctx context.Context, kubeconfigPath string, opts rolloutOptions, action rolloutAction, out io.Writer) error {
+	client, err := clientFactory(kubeconfigPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create Kubernetes client")
+		return err
+	}
+	if err := action(ctx, client, opts, out); err != nil {
+		log.Error().Err(err).Msg("Rollout command failed")
+		return err
+	}
+	return nil
+}
+
+// rolloutStatus печатает прогресс rollout, пока он не завершится, не истечет
+// progressDeadlineSeconds или --timeout
+func rolloutStatus(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error {
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	var last string
+	err := wait.PollUntilContextCancel(ctx, rolloutStatusInterval, true, func(ctx context.Context) (bool, error) {
+		d, err := client.GetDeployment(ctx, opts.namespace, opts.name)
+		if err != nil {
+			return false, fmt.Errorf("failed to get deployment %s/%s: %w", opts.namespace, opts.name, err)
+		}
+		message, done, err := rolloutStatusMessage(d)
+		if err != nil {
+			return false, err
+		}
+		if message != last {
+			fmt.Fprintln(out, message)
+			last = message
+		}
+		return done, nil
+	})
+	if opts.timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s waiting for deployment %s/%s to roll out", opts.timeout, opts.namespace, opts.name)
+	}
+	return err
+}
+
+// rolloutStatusMessage повторяет сообщения kubectl rollout status. done
+// означает, что rollout завершен; ошибка - что превышен progressDeadlineSeconds
+func rolloutStatusMessage(d *appsv1.Deployment) (string, bool, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return "Waiting for deployment spec update to be observed...", false, nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == progressDeadlineExceeded {
+			return "", false, fmt.Errorf("deployment %q exceeded its progress deadline", d.Name)
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	s := d.Status
+	switch {
+	case s.UpdatedReplicas < replicas:
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d out of %d new replicas have been updated...",
+			d.Name, s.UpdatedReplicas, replicas), false, nil
+	case s.Replicas > s.UpdatedReplicas:
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d old replicas are pending termination...",
+			d.Name, s.Replicas-s.UpdatedReplicas), false, nil
+	case s.AvailableReplicas < s.UpdatedReplicas:
+		return fmt.Sprintf("Waiting for deployment %q rollout to finish: %d of %d updated replicas are available...",
+			d.Name, s.AvailableReplicas, s.UpdatedReplicas), false, nil
+	}
+	return fmt.Sprintf("deployment %q successfully rolled out", d.Name), true, nil
+}
+
+// rolloutRestart меняет аннотацию restartedAt шаблона пода, из-за чего
+// контроллер Kubernetes пересоздает поды новым rollout
+func rolloutRestart(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error {
+	d, err := client.GetDeployment(ctx, opts.namespace, opts.name)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	if d.Spec.Paused {
+		return fmt.Errorf("can't restart paused deployment %s/%s (run rollout resume first)", opts.namespace, opts.name)
+	}
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339))
+	if _, err := client.PatchDeployment(ctx, opts.namespace, opts.name, types.StrategicMergePatchType, []byte(patch)); err != nil {
+		return fmt.Errorf("failed to restart deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	fmt.Fprintf(out, "deployment.apps/%s restarted\n", opts.name)
+	return nil
+}
+
+func rolloutPause(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error {
+	return setPaused(ctx, client, opts, true, out)
+}
+
+func rolloutResume(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error {
+	return setPaused(ctx, client, opts, false, out)
+}
+
+// setPaused выставляет spec.paused, если он еще не равен paused
+func setPaused(ctx context.Context, client KubernetesClient, opts rolloutOptions, paused bool, out io.Writer) error {
+	d, err := client.GetDeployment(ctx, opts.namespace, opts.name)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	switch {
+	case d.Spec.Paused && paused:
+		fmt.Fprintf(out, "deployment.apps/%s is already paused\n", opts.name)
+		return nil
+	case !d.Spec.Paused && !paused:
+		fmt.Fprintf(out, "deployment.apps/%s is not paused\n", opts.name)
+		return nil
+	}
+	patch := fmt.Sprintf(`{"spec":{"paused":%t}}`, paused)
+	if _, err := client.PatchDeployment(ctx, opts.namespace, opts.name, types.MergePatchType, []byte(patch)); err != nil {
+		return fmt.Errorf("failed to update deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	if paused {
+		fmt.Fprintf(out, "deployment.apps/%s paused\n", opts.name)
+	} else {
+		fmt.Fprintf(out, "deployment.apps/%s resumed\n", opts.name)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rolloutCmd)
+	rolloutCmd.AddCommand(rolloutStatusCmd, rolloutRestartCmd, rolloutPauseCmd, rolloutResumeCmd, rolloutHistoryCmd, rolloutUndoCmd)
+	rolloutCmd.PersistentFlags().StringVarP(&kubeconfig, "kubeconfig", "k", "", "Path to kubeconfig file")
+	rolloutCmd.PersistentFlags().StringVarP(&rolloutNamespace, "namespace", "n", metav1.NamespaceDefault, "Namespace of the deployment")
+	rolloutStatusCmd.Flags().DurationVar(&rolloutTimeout, "timeout", 0, "Maximum time to wait for the rollout (0 waits until the progress deadline)")
+	rolloutHistoryCmd.Flags().Int64Var(&rolloutHistoryRevision, "revision", 0, "Show the pod template of this revision")
+	rolloutUndoCmd.Flags().Int64Var(&rolloutUndoRevision, "to-revision", 0, "Revision to roll back to (0 is the previous revision)")
+}
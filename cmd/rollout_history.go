@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Аннотации, по которым Kubernetes нумерует ревизии деплоймента и хранит их причину
+const (
+	revisionAnnotation    = "deployment.kubernetes.io/revision"
+	changeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// deploymentRevision - ReplicaSet деплоймента и номер его ревизии
+type deploymentRevision struct {
+	number     int64
+	replicaSet *appsv1.ReplicaSet
+}
+
+// listRevisions возвращает ReplicaSet, принадлежащие деплойменту, по возрастанию ревизии
+func listRevisions(ctx context.Context, client KubernetesClient, d *appsv1.Deployment) ([]deploymentRevision, error) {
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of deployment %s/%s: %w", d.Namespace, d.Name, err)
+	}
+	list, err := client.ListReplicaSets(ctx, d.Namespace, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replica sets of deployment %s/%s: %w", d.Namespace, d.Name, err)
+	}
+
+	var revisions []deploymentRevision
+	for i := range list.Items {
+		rs := &list.Items[i]
+		// Селектор может совпасть с ReplicaSet другого владельца
+		if !metav1.IsControlledBy(rs, d) {
+			continue
+		}
+		number, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, deploymentRevision{number: number, replicaSet: rs})
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].number < revisions[j].number })
+	return revisions, nil
+}
+
+// rolloutHistory печатает ревизии с их change-cause, а с --revision - шаблон
+// пода выбранной ревизии
+func rolloutHistory(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error {
+	d, err := client.GetDeployment(ctx, opts.namespace, opts.name)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	revisions, err := listRevisions(ctx, client, d)
+	if err != nil {
+		return err
+	}
+
+	if opts.revision > 0 {
+		rev, err := findRevision(revisions, opts.revision)
+		if err != nil {
+			return fmt.Errorf("deployment %s/%s: %w", opts.namespace, opts.name, err)
+		}
+		data, err := yaml.Marshal(podTemplate(rev.replicaSet.Spec.Template))
+		if err != nil {
+			return fmt.Errorf("failed to encode pod template as YAML: %w", err)
+		}
+		fmt.Fprintf(out, "deployment.apps/%s with revision #%d\nPod Template:\n%s", opts.name, rev.number, data)
+		return nil
+	}
+
+	fmt.Fprintf(out, "deployment.apps/%s\n", opts.name)
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tCHANGE-CAUSE")
+	for _, rev := range revisions {
+		cause := rev.replicaSet.Annotations[changeCauseAnnotation]
+		if cause == "" {
+			cause = "<none>"
+		}
+		fmt.Fprintf(w, "%d\t%s\n", rev.number, cause)
+	}
+	return w.Flush()
+}
+
+// rolloutUndo копирует в деплоймент шаблон пода ReplicaSet выбранной ревизии;
+// без --to-revision выбирается ревизия, предшествующая текущей
+func rolloutUndo(ctx context.Context, client KubernetesClient, opts rolloutOptions, out io.Writer) error {
+	d, err := client.GetDeployment(ctx, opts.namespace, opts.name)
+	if err != nil {
+		return fmt.Errorf("failed to get deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	if d.Spec.Paused {
+		return fmt.Errorf("can't roll back paused deployment %s/%s (run rollout resume first)", opts.namespace, opts.name)
+	}
+	revisions, err := listRevisions(ctx, client, d)
+	if err != nil {
+		return err
+	}
+
+	var rev deploymentRevision
+	if opts.revision > 0 {
+		rev, err = findRevision(revisions, opts.revision)
+	} else {
+		rev, err = previousRevision(revisions)
+	}
+	if err != nil {
+		return fmt.Errorf("deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+
+	template := podTemplate(rev.replicaSet.Spec.Template)
+	if equality.Semantic.DeepEqual(template, podTemplate(d.Spec.Template)) {
+		fmt.Fprintf(out, "deployment.apps/%s skipped rollback (current template already matches revision %d)\n", opts.name, rev.number)
+		return nil
+	}
+
+	patch, err := rollbackPatch(d, rev.replicaSet, template)
+	if err != nil {
+		return err
+	}
+	if _, err := client.PatchDeployment(ctx, opts.namespace, opts.name, types.JSONPatchType, patch); err != nil {
+		return fmt.Errorf("failed to roll back deployment %s/%s: %w", opts.namespace, opts.name, err)
+	}
+	fmt.Fprintf(out, "deployment.apps/%s rolled back to revision %d\n", opts.name, rev.number)
+	return nil
+}
+
+// rollbackPatch заменяет шаблон и переносит change-cause ревизии, чтобы
+// history показывал причину, с которой шаблон был создан. Проверка
+// resourceVersion не дает затереть изменение, сделанное после чтения
+func rollbackPatch(d *appsv1.Deployment, rs *appsv1.ReplicaSet, template corev1.PodTemplateSpec) ([]byte, error) {
+	annotations := map[string]string{}
+	for k, v := range d.Annotations {
+		annotations[k] = v
+	}
+	if cause, ok := rs.Annotations[changeCauseAnnotation]; ok {
+		annotations[changeCauseAnnotation] = cause
+	} else {
+		delete(annotations, changeCauseAnnotation)
+	}
+
+	patch := []map[string]any{
+		{"op": "replace", "path": "/spec/template", "value": template},
+		{"op": "add", "path": "/metadata/annotations", "value": annotations},
+	}
+	if d.ResourceVersion != "" {
+		patch = append([]map[string]any{{"op": "test", "path": "/metadata/resourceVersion", "value": d.ResourceVersion}}, patch...)
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rollback patch: %w", err)
+	}
+	return data, nil
+}
+
+// podTemplate возвращает копию шаблона без метки pod-template-hash, которую
+// добавляет ReplicaSet, чтобы его можно было сравнить с шаблоном деплоймента
+func podTemplate(t corev1.PodTemplateSpec) corev1.PodTemplateSpec {
+	t = *t.DeepCopy()
+	delete(t.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	return t
+}
+
+func findRevision(revisions []deploymentRevision, number int64) (deploymentRevision, error) {
+	for _, rev := range revisions {
+		if rev.number == number {
+			return rev, nil
+		}
+	}
+	return deploymentRevision{}, fmt.Errorf("revision %d not found", number)
+}
+
+// previousRevision возвращает предпоследнюю ревизию; последняя - текущая
+func previousRevision(revisions []deploymentRevision) (deploymentRevision, error) {
+	if len(revisions) < 2 {
+		return deploymentRevision{}, fmt.Errorf("no rollout history found")
+	}
+	return revisions[len(revisions)-2], nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// revisionReplicaSet возвращает ReplicaSet деплоймента d с образом image
+func revisionReplicaSet(d *appsv1.Deployment, revision, image, cause string) *appsv1.ReplicaSet {
+	annotations := map[string]string{revisionAnnotation: revision}
+	if cause != "" {
+		annotations[changeCauseAnnotation] = cause
+	}
+	template := *d.Spec.Template.DeepCopy()
+	template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = "hash-" + revision
+	template.Spec.Containers[0].Image = image
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web-" + revision,
+			Namespace:       d.Namespace,
+			Labels:          template.Labels,
+			Annotations:     annotations,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: appsv1.ReplicaSetSpec{Selector: d.Spec.Selector, Template: template},
+	}
+}
+
+// historyObjects возвращает деплоймент на ревизии 3 и его ReplicaSet, а также
+// ReplicaSet другого владельца с тем же селектором
+func historyObjects(paused bool) []runtime.Object {
+	d := rolloutDeployment(paused)
+	d.Annotations = map[string]string{revisionAnnotation: "3", changeCauseAnnotation: "bump to 1.27"}
+	foreign := revisionReplicaSet(d, "9", "evil:latest", "")
+	foreign.Name = "foreign"
+	foreign.OwnerReferences = nil
+	return []runtime.Object{
+		d,
+		revisionReplicaSet(d, "3", "nginx:1.27", "bump to 1.27"),
+		revisionReplicaSet(d, "1", "nginx:1.25", "initial"),
+		revisionReplicaSet(d, "2", "nginx:1.26", ""),
+		foreign,
+	}
+}
+
+func TestRolloutHistory(t *testing.T) {
+	useFakeClientset(t, historyObjects(false)...)
+
+	output, err := runRollout(t, rolloutHistory, rolloutOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"deployment.apps/web"},
+		{"REVISION", "CHANGE-CAUSE"},
+		{"1", "initial"},
+		{"2", "<none>"},
+		{"3", "bump", "to", "1.27"},
+	}, fields(output))
+
+	t.Run("Revision details", func(t *testing.T) {
+		output, err := runRollout(t, rolloutHistory, rolloutOptions{revision: 2})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(output, "deployment.apps/web with revision #2\nPod Template:\n"))
+		assert.Contains(t, output, "image: nginx:1.26")
+		assert.NotContains(t, output, appsv1.DefaultDeploymentUniqueLabelKey)
+	})
+
+	t.Run("Unknown revision", func(t *testing.T) {
+		_, err := runRollout(t, rolloutHistory, rolloutOptions{revision: 9})
+		assert.ErrorContains(t, err, "revision 9 not found")
+	})
+}
+
+func TestRolloutUndo(t *testing.T) {
+	t.Run("Previous revision", func(t *testing.T) {
+		clientset := useFakeClientset(t, historyObjects(false)...)
+
+		output, err := runRollout(t, rolloutUndo, rolloutOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "deployment.apps/web rolled back to revision 2\n", output)
+
+		d := getDeployment(t, clientset)
+		assert.Equal(t, "nginx:1.26", d.Spec.Template.Spec.Containers[0].Image)
+		assert.NotContains(t, d.Spec.Template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+		assert.NotContains(t, d.Annotations, changeCauseAnnotation, "revision 2 has no change-cause")
+		assert.Equal(t, "3", d.Annotations[revisionAnnotation])
+	})
+
+	t.Run("To revision", func(t *testing.T) {
+		clientset := useFakeClientset(t, historyObjects(false)...)
+
+		output, err := runRollout(t, rolloutUndo, rolloutOptions{revision: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "deployment.apps/web rolled back to revision 1\n", output)
+
+		d := getDeployment(t, clientset)
+		assert.Equal(t, "nginx:1.25", d.Spec.Template.Spec.Containers[0].Image)
+		assert.Equal(t, "initial", d.Annotations[changeCauseAnnotation])
+	})
+
+	t.Run("Current revision", func(t *testing.T) {
+		useFakeClientset(t, historyObjects(false)...)
+		output, err := runRollout(t, rolloutUndo, rolloutOptions{revision: 3})
+		require.NoError(t, err)
+		assert.Contains(t, output, "skipped rollback")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		useFakeClientset(t, historyObjects(true)...)
+		_, err := runRollout(t, rolloutUndo, rolloutOptions{})
+		assert.ErrorContains(t, err, "can't roll back paused deployment")
+
+		useFakeClientset(t, historyObjects(false)...)
+		_, err = runRollout(t, rolloutUndo, rolloutOptions{revision: 9})
+		assert.ErrorContains(t, err, "revision 9 not found")
+
+		useFakeClientset(t, rolloutDeployment(false))
+		_, err = runRollout(t, rolloutUndo, rolloutOptions{})
+		assert.ErrorContains(t, err, "no rollout history found")
+	})
+}
+
+func TestRolloutCommandRegistered(t *testing.T) {
+	var names []string
+	for _, c := range rolloutCmd.Commands() {
+		names = append(names, c.Name())
+	}
+	assert.ElementsMatch(t, []string{"status", "restart", "pause", "resume", "history", "undo"}, names)
+}
+
+func TestRolloutRevisionFlags(t *testing.T) {
+	useFakeClientset(t, historyObjects(false)...)
+	require.NoError(t, rolloutUndoCmd.Flags().Set("to-revision", "1"))
+	defer func() { rolloutUndoRevision = 0 }()
+
+	// --to-revision команды undo не должен менять вывод history
+	buf := new(bytes.Buffer)
+	rolloutHistoryCmd.SetOut(buf)
+	rolloutHistoryCmd.SetContext(context.Background())
+	defer rolloutHistoryCmd.SetOut(nil)
+	require.NoError(t, rolloutHistoryCmd.RunE(rolloutHistoryCmd, []string{"web"}))
+	assert.Contains(t, buf.String(), "REVISION")
+	assert.Zero(t, rolloutHistoryRevision)
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// useFakeClientset подменяет clientFactory клиентом поверх fake clientset
+func useFakeClientset(t *testing.T, objects ...runtime.Object) *fake.Clientset {
+	t.Helper()
+	origFactory := clientFactory
+	t.Cleanup(func() { clientFactory = origFactory })
+	clientset := fake.NewClientset(objects...)
+	clientFactory = func(kubeconfigPath string) (KubernetesClient, error) {
+		return &DefaultKubernetesClient{clientset: clientset}, nil
+	}
+	return clientset
+}
+
+func rolloutDeployment(paused bool) *appsv1.Deployment {
+	replicas := int32(2)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Paused:   paused,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "nginx", Image: "nginx:1.27"}}},
+			},
+		},
+	}
+}
+
+func runRollout(t *testing.T, action rolloutAction, opts rolloutOptions) (string, error) {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	opts.namespace, opts.name = "default", "web"
+	err := runRolloutCommand(context.Background(), "test-kubeconfig", opts, action, buf)
+	return buf.String(), err
+}
+
+func getDeployment(t *testing.T, clientset *fake.Clientset) *appsv1.Deployment {
+	t.Helper()
+	d, err := clientset.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	return d
+}
+
+func TestRolloutStatusMessage(t *testing.T) {
+	d := rolloutDeployment(false)
+	d.Generation = 3
+
+	tests := []struct {
+		name    string
+		status  appsv1.DeploymentStatus
+		message string
+		done    bool
+	}{
+		{"Spec not observed", appsv1.DeploymentStatus{ObservedGeneration: 2},
+			"Waiting for deployment spec update to be observed...", false},
+		{"Updating", appsv1.DeploymentStatus{ObservedGeneration: 3, Replicas: 3, UpdatedReplicas: 1},
+			`Waiting for deployment "web" rollout to finish: 1 out of 2 new replicas have been updated...`, false},
+		{"Old replicas", appsv1.DeploymentStatus{ObservedGeneration: 3, Replicas: 3, UpdatedReplicas: 2},
+			`Waiting for deployment "web" rollout to finish: 1 old replicas are pending termination...`, false},
+		{"Unavailable", appsv1.DeploymentStatus{ObservedGeneration: 3, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+			`Waiting for deployment "web" rollout to finish: 1 of 2 updated replicas are available...`, false},
+		{"Complete", appsv1.DeploymentStatus{ObservedGeneration: 3, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+			`deployment "web" successfully rolled out`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d.Status = tt.status
+			message, done, err := rolloutStatusMessage(d)
+			require.NoError(t, err)
+			assert.Equal(t, tt.message, message)
+			assert.Equal(t, tt.done, done)
+		})
+	}
+
+	t.Run("Progress deadline exceeded", func(t *testing.T) {
+		d.Status = appsv1.DeploymentStatus{ObservedGeneration: 3, Conditions: []appsv1.DeploymentCondition{{
+			Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: progressDeadlineExceeded,
+		}}}
+		_, _, err := rolloutStatusMessage(d)
+		assert.ErrorContains(t, err, "exceeded its progress deadline")
+	})
+}
+
+func TestRolloutStatus(t *testing.T) {
+	origInterval := rolloutStatusInterval
+	defer func() { rolloutStatusInterval = origInterval }()
+	rolloutStatusInterval = time.Millisecond
+
+	t.Run("Follows progress until complete", func(t *testing.T) {
+		d := rolloutDeployment(false)
+		d.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 1}
+		clientset := useFakeClientset(t, d)
+
+		// Контроллер завершает rollout, пока команда ждет
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			d := d.DeepCopy()
+			d.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+			_, _ = clientset.AppsV1().Deployments("default").UpdateStatus(context.Background(), d, metav1.UpdateOptions{})
+		}()
+
+		output, err := runRollout(t, rolloutStatus, rolloutOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, `Waiting for deployment "web" rollout to finish: 1 out of 2 new replicas have been updated...`+"\n"+
+			`deployment "web" successfully rolled out`+"\n", output, "each message is printed once")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		useFakeClientset(t, rolloutDeployment(false))
+		_, err := runRollout(t, rolloutStatus, rolloutOptions{timeout: 10 * time.Millisecond})
+		assert.ErrorContains(t, err, "timed out after 10ms")
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		useFakeClientset(t)
+		_, err := runRollout(t, rolloutStatus, rolloutOptions{})
+		assert.ErrorContains(t, err, "not found")
+	})
+}
+
+func TestRolloutRestart(t *testing.T) {
+	clientset := useFakeClientset(t, rolloutDeployment(false))
+
+	output, err := runRollout(t, rolloutRestart, rolloutOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "deployment.apps/web restarted\n", output)
+
+	template := getDeployment(t, clientset).Spec.Template
+	restartedAt, err := time.Parse(time.RFC3339, template.Annotations[restartedAtAnnotation])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), restartedAt, time.Minute)
+	assert.Equal(t, "nginx:1.27", template.Spec.Containers[0].Image, "the rest of the template is kept")
+
+	t.Run("Paused", func(t *testing.T) {
+		useFakeClientset(t, rolloutDeployment(true))
+		_, err := runRollout(t, rolloutRestart, rolloutOptions{})
+		assert.ErrorContains(t, err, "can't restart paused deployment")
+	})
+}
+
+func TestRolloutPauseResume(t *testing.T) {
+	clientset := useFakeClientset(t, rolloutDeployment(false))
+
+	output, err := runRollout(t, rolloutPause, rolloutOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "deployment.apps/web paused\n", output)
+	assert.True(t, getDeployment(t, clientset).Spec.Paused)
+
+	output, err = runRollout(t, rolloutPause, rolloutOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "deployment.apps/web is already paused\n", output)
+
+	output, err = runRollout(t, rolloutResume, rolloutOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "deployment.apps/web resumed\n", output)
+	assert.False(t, getDeployment(t, clientset).Spec.Paused)
+
+	output, err = runRollout(t, rolloutResume, rolloutOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "deployment.apps/web is not paused\n", output)
+}
@@ -24,7 +24,8 @@ var rootCmd = &cobra.Command{
 	Use:   "k8s-controller-tutorial",
 	Short: "A brief description of your application",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("%s version: %s\n", cmd.Root().Name(), appVersion)
+		// Stderr keeps stdout parseable, e.g. for list -o json
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s version: %s\n", cmd.Root().Name(), appVersion)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Welcome to k8s-controller-tutorial CLI!")
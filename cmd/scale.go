@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+var scaleNamespace string
+var scaleReplicas int32
+var scaleCurrentReplicas int32
+var scaleWait bool
+var scaleTimeout time.Duration
+
+// scaleWaitInterval - период опроса деплоймента при --wait
+var scaleWaitInterval = time.Second
+
+// scaleCmd represents the scale command
+var scaleCmd = &cobra.Command{
+	Use:   "scale NAME --replicas N",
+	Short: "Set the number of replicas of a deployment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return runScaleCommand(ctx, kubeconfig, scaleOptions{
+			namespace:       scaleNamespace,
+			name:            args[0],
+			replicas:        scaleReplicas,
+			currentReplicas: scaleCurrentReplicas,
+			wait:            scaleWait,
+			timeout:         scaleTimeout,
+		}, cmd.OutOrStdout())
+	},
+}
+
+// scaleOptions - аргументы и флаги команды scale
+type scaleOptions struct {
+	namespace string
+	name      string
+	replicas  int32
+	// currentReplicas < 0 отключает проверку текущего числа реплик
+	currentReplicas int32
+	wait            bool
+	timeout         time.Duration
+}
+
+// runScaleCommand меняет число реплик через subresource scale и при --wait
+// ждет, пока все реплики станут готовыми
+func runScaleCommand(ctx context.Context, kubeconfigPath string, opts scaleOptions, out io.Writer) error {
+	if opts.replicas < 0 {
+		return fmt.Errorf("--replicas must not be negative, got %d", opts.replicas)
+	}
+
+	client, err := clientFactory(kubeconfigPath)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create Kubernetes client")
+		return err
+	}
+
+	if err := scaleDeployment(ctx, client, opts); err != nil {
+		log.Error().Err(err).Msg("Failed to scale deployment")
+		return err
+	}
+	fmt.Fprintf(out, "deployment.apps/%s scaled to %d replicas\n", opts.name, opts.replicas)
+
+	if !opts.wait {
+		return nil
+	}
+	if err := waitForReplicas(ctx, client, opts); err != nil {
+		log.Error().Err(err).Msg("Failed to wait for deployment")
+		return err
+	}
+	fmt.Fprintf(out, "deployment.apps/%s has %d ready replicas\n", opts.name, opts.replicas)
+	return nil
+}
+
+// scaleDeployment обновляет spec.replicas. С --current-replicas обновление
+// выполняется с resourceVersion прочитанного Scale, поэтому параллельное
+// изменение приводит к ошибке, а не к повтору
+func scaleDeployment(ctx context.Context, client KubernetesClient, opts scaleOptions) error {
+	update := func() error {
+		scale, err := client.GetScale(ctx, opts.namespace, opts.name)
+		if err != nil {
+			return fmt.Errorf("failed to get scale of deployment %s/%s: %w", opts.namespace, opts.name, err)
+		}
+		if opts.currentReplicas >= 0 && scale.Spec.Replicas != opts.currentReplicas {
+			return fmt.Errorf("expected deployment %s/%s to have %d replicas, but it has %d",
+				opts.namespace, opts.name, opts.currentReplicas, scale.Spec.Replicas)
+		}
+		scale.Spec.Replicas = opts.replicas
+		if _, err := client.UpdateScale(ctx, opts.namespace, opts.name, scale); err != nil {
+			return fmt.Errorf("failed to scale deployment %s/%s: %w", opts.namespace, opts.name, err)
+		}
+		return nil
+	}
+	if opts.currentReplicas >= 0 {
+		return update()
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, update)
+}
+
+// waitForReplicas опрашивает деплоймент, пока контроллер не применит новое
+// число реплик и все они не станут готовыми
+func waitForReplicas(ctx context.Context, client KubernetesClient, opts scaleOptions) error {
+	var last *appsv1.Deployment
+	err := wait.PollUntilContextTimeout(ctx, scaleWaitInterval, opts.timeout, true, func(ctx context.Context) (bool, error) {
+		d, err := client.GetDeployment(ctx, opts.namespace, opts.name)
+		if err != nil {
+			return false, fmt.Errorf("failed to get deployment %s/%s: %w", opts.namespace, opts.name, err)
+		}
+		last = d
+		return replicasReady(d, opts.replicas), nil
+	})
+	if err == nil {
+		return nil
+	}
+	if last != nil && errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s waiting for deployment %s/%s: %d of %d replicas ready",
+			opts.timeout, opts.namespace, opts.name, last.Status.ReadyReplicas, opts.replicas)
+	}
+	return err
+}
+
+// replicasReady сообщает, что контроллер видел последнюю спецификацию и
+// реплик ровно столько, сколько запрошено, и все они готовы
+func replicasReady(d *appsv1.Deployment, replicas int32) bool {
+	s := d.Status
+	return s.ObservedGeneration >= d.Generation && s.Replicas == replicas && s.ReadyReplicas == replicas
+}
+
+func init() {
+	rootCmd.AddCommand(scaleCmd)
+	scaleCmd.Flags().StringVarP(&kubeconfig, "kubeconfig", "k", "", "Path to kubeconfig file")
+	scaleCmd.Flags().StringVarP(&scaleNamespace, "namespace", "n", metav1.NamespaceDefault, "Namespace of the deployment")
+	scaleCmd.Flags().Int32Var(&scaleReplicas, "replicas", 0, "New number of replicas")
+	scaleCmd.Flags().Int32Var(&scaleCurrentReplicas, "current-replicas", -1, "Only scale if the deployment currently has this many replicas (-1 skips the check)")
+	scaleCmd.Flags().BoolVar(&scaleWait, "wait", false, "Wait until the new number of replicas is ready")
+	scaleCmd.Flags().DurationVar(&scaleTimeout, "timeout", 5*time.Minute, "Maximum time to wait with --wait")
+	_ = scaleCmd.MarkFlagRequired("replicas")
+}
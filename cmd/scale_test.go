@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// scaleClient имитирует subresource scale одного деплоймента
+type scaleClient struct {
+	MockKubernetesClient
+
+	replicas int32
+	// conflicts - сколько первых UpdateScale завершатся конфликтом
+	conflicts int
+	updates   []*autoscalingv1.Scale
+	// status возвращает деплоймент для n-го вызова GetDeployment
+	status func(n int) appsv1.DeploymentStatus
+	gets   int
+}
+
+func (c *scaleClient) GetScale(ctx context.Context, namespace, name string) (*autoscalingv1.Scale, error) {
+	if name != "web" {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, name)
+	}
+	return &autoscalingv1.Scale{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: "7"},
+		Spec:       autoscalingv1.ScaleSpec{Replicas: c.replicas},
+	}, nil
+}
+
+func (c *scaleClient) UpdateScale(ctx context.Context, namespace, name string, scale *autoscalingv1.Scale) (*autoscalingv1.Scale, error) {
+	c.updates = append(c.updates, scale)
+	if len(c.updates) <= c.conflicts {
+		return nil, apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, name, fmt.Errorf("object was modified"))
+	}
+	c.replicas = scale.Spec.Replicas
+	return scale, nil
+}
+
+func (c *scaleClient) GetDeployment(ctx context.Context, namespace, name string) (*appsv1.Deployment, error) {
+	c.gets++
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Generation: 2},
+		Status:     c.status(c.gets),
+	}, nil
+}
+
+func TestRunScaleCommand(t *testing.T) {
+	origFactory, origInterval := clientFactory, scaleWaitInterval
+	defer func() {
+		clientFactory, scaleWaitInterval = origFactory, origInterval
+	}()
+	scaleWaitInterval = time.Millisecond
+	useClient := func(c KubernetesClient) {
+		clientFactory = func(kubeconfigPath string) (KubernetesClient, error) { return c, nil }
+	}
+	opts := scaleOptions{namespace: "default", name: "web", replicas: 3, currentReplicas: -1}
+
+	t.Run("Success", func(t *testing.T) {
+		client := &scaleClient{replicas: 1}
+		useClient(client)
+		buf := new(bytes.Buffer)
+
+		require.NoError(t, runScaleCommand(context.Background(), "test-kubeconfig", opts, buf))
+
+		assert.Equal(t, int32(3), client.replicas)
+		assert.Equal(t, "deployment.apps/web scaled to 3 replicas\n", buf.String())
+	})
+
+	t.Run("Retries conflicts without precondition", func(t *testing.T) {
+		client := &scaleClient{replicas: 1, conflicts: 2}
+		useClient(client)
+
+		require.NoError(t, runScaleCommand(context.Background(), "test-kubeconfig", opts, new(bytes.Buffer)))
+
+		assert.Len(t, client.updates, 3)
+		assert.Equal(t, int32(3), client.replicas)
+	})
+
+	t.Run("Current replicas precondition", func(t *testing.T) {
+		client := &scaleClient{replicas: 2}
+		useClient(client)
+		precondition := opts
+		precondition.currentReplicas = 1
+
+		err := runScaleCommand(context.Background(), "test-kubeconfig", precondition, new(bytes.Buffer))
+
+		assert.ErrorContains(t, err, "expected deployment default/web to have 1 replicas, but it has 2")
+		assert.Empty(t, client.updates)
+
+		precondition.currentReplicas = 2
+		require.NoError(t, runScaleCommand(context.Background(), "test-kubeconfig", precondition, new(bytes.Buffer)))
+		assert.Equal(t, "7", client.updates[0].ResourceVersion, "the update is conditional on the checked version")
+	})
+
+	t.Run("Conflict with precondition", func(t *testing.T) {
+		client := &scaleClient{replicas: 1, conflicts: 1}
+		useClient(client)
+		precondition := opts
+		precondition.currentReplicas = 1
+
+		err := runScaleCommand(context.Background(), "test-kubeconfig", precondition, new(bytes.Buffer))
+
+		assert.True(t, apierrors.IsConflict(err))
+		assert.Len(t, client.updates, 1, "a concurrent change is not retried")
+	})
+
+	t.Run("Deployment not found", func(t *testing.T) {
+		useClient(&scaleClient{})
+		missing := opts
+		missing.name = "api"
+
+		err := runScaleCommand(context.Background(), "test-kubeconfig", missing, new(bytes.Buffer))
+
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Negative replicas", func(t *testing.T) {
+		negative := opts
+		negative.replicas = -1
+
+		assert.ErrorContains(t, runScaleCommand(context.Background(), "test-kubeconfig", negative, new(bytes.Buffer)), "must not be negative")
+	})
+
+	t.Run("Wait", func(t *testing.T) {
+		client := &scaleClient{replicas: 1, status: func(n int) appsv1.DeploymentStatus {
+			switch n {
+			case 1:
+				// Контроллер еще не видел новую спецификацию
+				return appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, ReadyReplicas: 3}
+			case 2:
+				return appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, ReadyReplicas: 2}
+			}
+			return appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, ReadyReplicas: 3}
+		}}
+		useClient(client)
+		wait := opts
+		wait.wait, wait.timeout = true, time.Minute
+		buf := new(bytes.Buffer)
+
+		require.NoError(t, runScaleCommand(context.Background(), "test-kubeconfig", wait, buf))
+
+		assert.Equal(t, 3, client.gets)
+		assert.Contains(t, buf.String(), "deployment.apps/web has 3 ready replicas")
+	})
+
+	t.Run("Wait timeout", func(t *testing.T) {
+		useClient(&scaleClient{replicas: 1, status: func(int) appsv1.DeploymentStatus {
+			return appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, ReadyReplicas: 1}
+		}})
+		wait := opts
+		wait.wait, wait.timeout = true, 20*time.Millisecond
+
+		err := runScaleCommand(context.Background(), "test-kubeconfig", wait, new(bytes.Buffer))
+
+		assert.ErrorContains(t, err, "timed out after 20ms waiting for deployment default/web: 1 of 3 replicas ready")
+	})
+}
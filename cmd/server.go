@@ -1,22 +1,46 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/api/v1alpha1"
 	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
 	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/informer"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/webhook"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/valyala/fasthttp"
+	"golang.org/x/sync/errgroup"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrlruntime "sigs.k8s.io/controller-runtime"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 var serverPort int
@@ -24,73 +48,281 @@ var serverInCluster bool
 var serverKubeConfig string
 var metricsPort int
 var enableLeaderElection bool
+var informerNamespaces []string
+var informerAllNamespaces bool
+var excludedNamespaces []string
+var deploymentSelector string
+var informerSyncTimeout time.Duration
+var livenessResyncs int
+var shutdownTimeout time.Duration
+var imagePolicyPath string
+var enableWebhooks bool
+var webhookPort int
+var webhookCertDir string
+var validationRulesPath string
+var defaultingConfigPath string
+var controllerQueue ctrl.QueueOptions
+var controllerDryRun bool
 
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start a FastHTTP server and deployment informer",
 	Run: func(cmd *cobra.Command, args []string) {
-		clientset, err := getServerKubeClient(serverKubeConfig, serverInCluster)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to create kubernetes client.")
-			os.Exit(1)
-		}
-		ctx := context.Background()
-		go informer.StartDeploymentInformer(ctx, clientset)
-
-		mgr, err := ctrlruntime.NewManager(ctrlruntime.GetConfigOrDie(), manager.Options{
-			Metrics:                 server.Options{BindAddress: fmt.Sprintf(":%d", metricsPort)},
-			LeaderElection:          enableLeaderElection,
-			LeaderElectionID:        "k8s-controller-tutorial-leader-election",
-			LeaderElectionNamespace: "default",
-		})
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to create controller-runtime manager")
-			os.Exit(1)
-		}
-		if err := ctrl.AddDeploymentController(mgr); err != nil {
-			log.Error().Err(err).Msg("Failed to add deployment controller")
-			os.Exit(1)
-		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 		go func() {
-			log.Info().Msg("Starting controller-runtime manager...")
-			if err := mgr.Start(cmd.Context()); err != nil {
-				log.Error().Err(err).Msg("Error starting controller-runtime manager")
-				os.Exit(1)
-			}
+			<-ctx.Done()
+			// Restore default signal handling so that a second signal kills the process.
+			stop()
 		}()
+		if err := runServer(ctx); err != nil {
+			log.Error().Err(err).Msg("Server failed")
+			os.Exit(exitCode(err))
+		}
+		log.Info().Msg("Server stopped")
+	},
+}
+
+// informerComponent is the source of the events recorded by the deployment informer.
+const informerComponent = "deployment-informer"
+
+// Exit codes of the server command. A clean shutdown after SIGINT or SIGTERM exits with 0.
+const (
+	exitFailure         = 1
+	exitShutdownTimeout = 2
+)
+
+// errShutdownTimeout reports that in-flight requests were still running when the shutdown timeout expired.
+var errShutdownTimeout = errors.New("graceful shutdown timed out")
+
+func exitCode(err error) int {
+	if errors.Is(err, errShutdownTimeout) {
+		return exitShutdownTimeout
+	}
+	return exitFailure
+}
+
+// runServer starts the deployment informer, the controller-runtime manager and the
+// FastHTTP server. It returns when ctx is done or as soon as one of them fails,
+// after stopping the others, so that the command decides how to exit. On
+// shutdown the server stops accepting connections and drains in-flight
+// requests, the informers are stopped and the leader-election lease is released.
+func runServer(ctx context.Context) error {
+	clientset, err := getServerKubeClient(serverKubeConfig, serverInCluster)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return fmt.Errorf("failed to register Kubernetes types: %w", err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return fmt.Errorf("failed to register %s types: %w", v1alpha1.GroupVersion, err)
+	}
+	sel, err := deploymentSelection(watchedNamespaces(informerNamespaces, informerAllNamespaces), excludedNamespaces, deploymentSelector)
+	if err != nil {
+		return err
+	}
+	mgr, err := ctrlruntime.NewManager(ctrlruntime.GetConfigOrDie(), manager.Options{
+		Scheme:                  scheme,
+		Cache:                   managerCacheOptions(sel),
+		Metrics:                 server.Options{BindAddress: fmt.Sprintf(":%d", metricsPort)},
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        "k8s-controller-tutorial-leader-election",
+		LeaderElectionNamespace: "default",
+		// Let a standby replica take over immediately instead of waiting for the lease to expire.
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &shutdownTimeout,
+		WebhookServer:                 crwebhook.NewServer(crwebhook.Options{Port: webhookPort, CertDir: webhookCertDir}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create controller-runtime manager: %w", err)
+	}
+	deploymentCache := informer.NewDeploymentCache(clientset, informer.Options{
+		Namespaces: sel.Namespaces,
+		Recorder:   events.NewRecorder(mgr.GetEventRecorderFor(informerComponent)),
+		Selection:  sel,
+	})
+	controllerOpts := ctrl.DeploymentOptions{Queue: controllerQueue, Selection: sel, DryRun: controllerDryRun}
+	if controllerDryRun {
+		log.Warn().Msg("Deployment controller runs in dry-run mode: changes are reported but not applied")
+	}
+	if imagePolicyPath != "" {
+		if controllerOpts.ImagePolicy, err = ctrl.LoadImagePolicy(imagePolicyPath); err != nil {
+			return err
+		}
+		log.Info().Str("mode", string(controllerOpts.ImagePolicy.Mode)).Msgf("Loaded image policy from %s", imagePolicyPath)
+	}
+	if err := ctrl.AddDeploymentController(mgr, controllerOpts); err != nil {
+		return fmt.Errorf("failed to add deployment controller: %w", err)
+	}
+	if err := addApplicationController(mgr, sel); err != nil {
+		return fmt.Errorf("failed to add application controller: %w", err)
+	}
+	if enableWebhooks {
+		if err := addWebhooks(mgr); err != nil {
+			return fmt.Errorf("failed to add webhooks: %w", err)
+		}
+	}
+	mgrStatus := &managerStatus{}
+	if err := mgr.Add(mgrStatus); err != nil {
+		return fmt.Errorf("failed to add manager status runnable: %w", err)
+	}
 
-		handler := createHandler(&informer.DeploymentInformer{})
-		addr := fmt.Sprintf(":%d", serverPort)
+	addr := fmt.Sprintf(":%d", serverPort)
+	ln, err := net.Listen("tcp4", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &fasthttp.Server{Handler: createHandler(deploymentCache, serverHealthChecks(deploymentCache, mgrStatus, livenessResyncs))}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		if err := deploymentCache.Run(ctx, informerSyncTimeout); err != nil {
+			return fmt.Errorf("deployment informer: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		log.Info().Msg("Starting controller-runtime manager...")
+		if err := mgr.Start(ctx); err != nil {
+			return fmt.Errorf("controller-runtime manager: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
 		log.Info().Msgf("Starting FastHTTP server on %s", addr)
-		if err := fasthttp.ListenAndServe(addr, handler); err != nil {
-			log.Error().Err(err).Msg("Error starting FastHTTP server")
-			os.Exit(1)
+		if err := srv.Serve(ln); err != nil {
+			return fmt.Errorf("FastHTTP server: %w", err)
 		}
-	},
+		return nil
+	})
+	g.Go(func() error {
+		<-ctx.Done()
+		log.Info().Dur("timeout", shutdownTimeout).Msg("Shutting down FastHTTP server...")
+		return shutdownHTTPServer(srv, ln, shutdownTimeout)
+	})
+	return g.Wait()
+}
+
+// addApplicationController registers the Application controller when the CRD is
+// installed, so that clusters without it still run the deployment controller.
+func addApplicationController(mgr manager.Manager, sel selection.Config) error {
+	gvk := v1alpha1.GroupVersion.WithKind("Application")
+	if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			log.Warn().Msgf("%s CRD is not installed, the Application controller is disabled", gvk.GroupKind())
+			return nil
+		}
+		return fmt.Errorf("failed to look up %s: %w", gvk.GroupKind(), err)
+	}
+	return ctrl.AddApplicationController(mgr, sel)
+}
+
+// addWebhooks registers the admission webhooks. The webhook server only starts
+// listening when at least one webhook is registered.
+func addWebhooks(mgr manager.Manager) error {
+	rules := webhook.DefaultValidationRules()
+	if validationRulesPath != "" {
+		var err error
+		if rules, err = webhook.LoadValidationRules(validationRulesPath); err != nil {
+			return err
+		}
+	}
+	defaults := webhook.DefaultDefaultingConfig()
+	if defaultingConfigPath != "" {
+		var err error
+		if defaults, err = webhook.LoadDefaultingConfig(defaultingConfigPath); err != nil {
+			return err
+		}
+	}
+	if err := webhook.SetupDeploymentDefaulter(mgr, defaults); err != nil {
+		return err
+	}
+	log.Info().Str("mode", string(rules.Mode)).Msgf("Serving Deployment mutating and validating webhooks on :%d", webhookPort)
+	return webhook.SetupDeploymentValidator(mgr, rules)
+}
+
+// shutdownHTTPServer stops accepting connections and waits up to timeout for
+// in-flight requests to finish. Open connections are left to be closed on exit.
+func shutdownHTTPServer(srv *fasthttp.Server, ln net.Listener, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.ShutdownWithContext(ctx)
+	// Serve may not have registered the listener yet; closing it unblocks Serve either way.
+	_ = ln.Close()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: %d connections still open", errShutdownTimeout, timeout, srv.GetOpenConnectionsCount())
+	}
+	return err
 }
 
-func createHandler(lister informer.DeploymentLister) fasthttp.RequestHandler {
+func createHandler(lister informer.DeploymentLister, health healthChecks) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		requestID := uuid.New().String()
 		ctx.Response.Header.Set("X-Request-ID", requestID)
 		logger := log.With().Str("request_id", requestID).Logger()
-		switch string(ctx.Path()) {
-		case "/deployments":
-			ctx.Response.Header.Set("Content-Type", "application/json")
-			deployments := lister.GetDeploymentsNames()
-			logger.Info().Msgf("Deployments: %v", deployments)
-			ctx.SetStatusCode(200)
-			ctx.Write([]byte("["))
-			for i, name := range deployments {
-				ctx.WriteString("\"")
-				ctx.WriteString(name)
-				ctx.WriteString("\"")
-				if i < len(deployments)-1 {
-					ctx.WriteString(",")
-				}
+		path := string(ctx.Path())
+		switch {
+		case path == "/healthz":
+			logger.Debug().Msg("Liveness probe")
+			writeHealth(ctx, health.liveness)
+			return
+		case path == "/readyz":
+			logger.Debug().Msg("Readiness probe")
+			writeHealth(ctx, health.readiness)
+			return
+		case path == "/deployments":
+			filter, err := parseDeploymentFilter(ctx.QueryArgs())
+			if err != nil {
+				logger.Info().Err(err).Msg("Invalid deployment query")
+				writeError(ctx, fasthttp.StatusBadRequest, err.Error())
+				return
+			}
+			page, err := parsePageRequest(ctx.QueryArgs())
+			if err != nil {
+				logger.Info().Err(err).Msg("Invalid deployment query")
+				writeError(ctx, fasthttp.StatusBadRequest, err.Error())
+				return
 			}
-			ctx.Write([]byte("]"))
+			deployments := informer.FilterDeployments(lister.List(filter.Namespace), filter)
+			informer.SortDeployments(deployments, page.sort)
+			deployments, next, err := informer.Paginate(deployments, page.sort, page.limit, page.continueToken)
+			if err != nil {
+				logger.Info().Err(err).Msg("Invalid continue token")
+				writeError(ctx, fasthttp.StatusBadRequest, err.Error())
+				return
+			}
+			summaries := make([]informer.DeploymentSummary, 0, len(deployments))
+			for _, d := range deployments {
+				summaries = append(summaries, informer.NewDeploymentSummary(d))
+			}
+			if next != "" {
+				ctx.Response.Header.Set(continueHeader, next)
+			}
+			logger.Info().Int("count", len(summaries)).Msg("Listing deployments")
+			writeJSON(ctx, fasthttp.StatusOK, summaries)
+			return
+		case path == "/deployments/watch":
+			logger.Info().Msg("Starting deployment event stream")
+			streamDeploymentEvents(ctx, lister)
+			return
+		case strings.HasPrefix(path, deploymentsPrefix):
+			namespace, name, err := parseDeploymentPath(path)
+			if err != nil {
+				logger.Info().Err(err).Str("path", path).Msg("Malformed deployment path")
+				writeError(ctx, fasthttp.StatusBadRequest, err.Error())
+				return
+			}
+			deployment, ok := lister.Get(namespace, name)
+			if !ok {
+				logger.Info().Msgf("Deployment %s/%s not found", namespace, name)
+				writeError(ctx, fasthttp.StatusNotFound, fmt.Sprintf("deployment %s/%s not found", namespace, name))
+				return
+			}
+			writeJSON(ctx, fasthttp.StatusOK, informer.NewDeploymentDetail(deployment, lister.ReplicaSets(deployment)))
 			return
 		default:
 			logger.Info().Msg("Default path received")
@@ -99,6 +331,220 @@ func createHandler(lister informer.DeploymentLister) fasthttp.RequestHandler {
 	}
 }
 
+const deploymentsPrefix = "/deployments/"
+
+// parseDeploymentPath extracts and validates the namespace and name from /deployments/{namespace}/{name}.
+func parseDeploymentPath(path string) (string, string, error) {
+	parts := strings.Split(strings.TrimPrefix(path, deploymentsPrefix), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected path %s{namespace}/{name}", deploymentsPrefix)
+	}
+	namespace, name := parts[0], parts[1]
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf("invalid name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return namespace, name, nil
+}
+
+// parseDeploymentFilter builds a filter from the labelSelector, namespace, image and ready query parameters.
+func parseDeploymentFilter(args *fasthttp.Args) (informer.DeploymentFilter, error) {
+	var filter informer.DeploymentFilter
+	if v := string(args.Peek("labelSelector")); v != "" {
+		selector, err := labels.Parse(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid labelSelector: %w", err)
+		}
+		filter.Selector = selector
+	}
+	if v := string(args.Peek("namespace")); v != "" {
+		if errs := validation.IsDNS1123Label(v); len(errs) > 0 {
+			return filter, fmt.Errorf("invalid namespace %q: %s", v, strings.Join(errs, "; "))
+		}
+		filter.Namespace = v
+	}
+	filter.Image = string(args.Peek("image"))
+	if v := string(args.Peek("ready")); v != "" {
+		ready, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid ready value %q: expected true or false", v)
+		}
+		filter.Ready = &ready
+	}
+	return filter, nil
+}
+
+const (
+	// sseRetry tells EventSource clients how long to wait before reconnecting.
+	sseRetry = 3 * time.Second
+	// sseHeartbeat keeps idle streams open through proxies and detects gone clients.
+	sseHeartbeat = 15 * time.Second
+	// resetEvent precedes a fresh snapshot when the requested event ID can no longer be resumed.
+	resetEvent = "RESET"
+)
+
+// streamDeploymentEvents serves deployment changes as Server-Sent Events. Clients resume
+// with the Last-Event-ID header or the lastEventId query parameter; otherwise, or when
+// the event is too old, the stream starts with the current deployments as ADDED events.
+func streamDeploymentEvents(ctx *fasthttp.RequestCtx, lister informer.DeploymentLister) {
+	lastEventID := string(ctx.Request.Header.Peek("Last-Event-ID"))
+	if v := string(ctx.QueryArgs().Peek("lastEventId")); v != "" {
+		lastEventID = v
+	}
+	sub := lister.Watch(lastEventID)
+
+	ctx.Response.Header.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+		if sub.Resumed {
+			for _, ev := range sub.Replay {
+				writeSSE(w, ev.ID, string(ev.Type), ev)
+			}
+		} else {
+			if lastEventID != "" {
+				writeSSE(w, "", resetEvent, map[string]string{"lastEventId": lastEventID})
+			}
+			// Snapshot events carry no id, so a reconnect before the first live event starts over.
+			deployments := lister.List(metav1.NamespaceAll)
+			informer.SortDeployments(deployments, informer.SortByName)
+			for _, d := range deployments {
+				writeSSE(w, "", string(informer.EventAdded), informer.DeploymentEvent{
+					Type:            informer.EventAdded,
+					ResourceVersion: d.ResourceVersion,
+					Deployment:      informer.NewDeploymentSummary(d),
+				})
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case ev, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				writeSSE(w, ev.ID, string(ev.Type), ev)
+			case <-heartbeat.C:
+				w.WriteString(": heartbeat\n\n")
+			}
+			if err := w.Flush(); err != nil {
+				log.Debug().Err(err).Msg("Deployment event stream closed by client")
+				return
+			}
+		}
+	})
+}
+
+// writeSSE writes a single Server-Sent Event with a JSON payload.
+func writeSSE(w *bufio.Writer, id, event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode deployment event")
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+// continueHeader carries the token for the next page of a limited listing.
+const continueHeader = "X-Continue"
+
+// pageRequest holds the sort, limit and continue query parameters.
+type pageRequest struct {
+	sort          informer.SortField
+	limit         int
+	continueToken string
+}
+
+func parsePageRequest(args *fasthttp.Args) (pageRequest, error) {
+	var page pageRequest
+	sortField, err := informer.ParseSortField(string(args.Peek("sort")))
+	if err != nil {
+		return page, err
+	}
+	page.sort = sortField
+	if v := string(args.Peek("limit")); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return page, fmt.Errorf("invalid limit %q: expected a positive integer", v)
+		}
+		page.limit = limit
+	}
+	page.continueToken = string(args.Peek("continue"))
+	return page, nil
+}
+
+// writeError writes a JSON error body with the given status code.
+func writeError(ctx *fasthttp.RequestCtx, status int, message string) {
+	writeJSON(ctx, status, map[string]string{"error": message})
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(ctx *fasthttp.RequestCtx, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode JSON response")
+		ctx.Error(`{"error":"failed to encode response"}`, fasthttp.StatusInternalServerError)
+		ctx.Response.Header.SetContentType("application/json")
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json")
+	ctx.SetStatusCode(status)
+	ctx.SetBody(body)
+}
+
+// watchedNamespaces resolves the --namespace and --all-namespaces flags into the informer namespace list.
+func watchedNamespaces(namespaces []string, all bool) []string {
+	if all {
+		return []string{metav1.NamespaceAll}
+	}
+	return informer.NormalizeNamespaces(namespaces)
+}
+
+// deploymentSelection builds the selection shared by the deployment informer and controller.
+func deploymentSelection(namespaces, excluded []string, labelSelector string) (selection.Config, error) {
+	sel := selection.Config{Namespaces: namespaces, ExcludedNamespaces: excluded}
+	if labelSelector != "" {
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return sel, fmt.Errorf("invalid --selector: %w", err)
+		}
+		sel.Selector = selector
+	}
+	return sel, nil
+}
+
+// managerCacheOptions makes the API server filter what the controller-runtime
+// cache holds: only the selected namespaces are watched, excluded namespaces
+// are left out for every resource and Deployments are listed with the label selector.
+func managerCacheOptions(sel selection.Config) crcache.Options {
+	opts := crcache.Options{
+		DefaultFieldSelector: sel.FieldSelector(),
+		ByObject: map[client.Object]crcache.ByObject{
+			&appsv1.Deployment{}: {Label: sel.LabelSelector()},
+		},
+	}
+	if namespaces := informer.NormalizeNamespaces(sel.Namespaces); namespaces[0] != metav1.NamespaceAll {
+		opts.DefaultNamespaces = map[string]crcache.Config{}
+		for _, ns := range namespaces {
+			opts.DefaultNamespaces[ns] = crcache.Config{}
+		}
+	}
+	return opts
+}
+
 func getServerKubeClient(kubeconfigPath string, inCluster bool) (*kubernetes.Clientset, error) {
 	var config *rest.Config
 	var err error
@@ -120,4 +566,24 @@ func init() {
 	serverCmd.Flags().BoolVar(&serverInCluster, "in-cluster", false, "Use in-cluster kubeconfg")
 	serverCmd.Flags().IntVar(&metricsPort, "metrics-port", 8081, "Port for metrics server")
 	serverCmd.Flags().BoolVar(&enableLeaderElection, "enable-leader-election", true, "Enable leader election for controller manager")
+	serverCmd.Flags().StringSliceVar(&informerNamespaces, "namespace", nil, "Namespaces watched by the deployment informer and reconciled by the controllers, repeat or comma-separate (default all namespaces)")
+	serverCmd.Flags().BoolVar(&informerAllNamespaces, "all-namespaces", false, "Watch deployments in all namespaces (overrides --namespace)")
+	serverCmd.Flags().StringSliceVar(&excludedNamespaces, "exclude-namespace", nil, "Namespaces ignored by the deployment informer and controller (repeat or comma-separate)")
+	serverCmd.Flags().StringVar(&deploymentSelector, "selector", "", "Label selector limiting the Deployments watched and reconciled, e.g. team=payments")
+	serverCmd.Flags().DurationVar(&informerSyncTimeout, "informer-sync-timeout", 2*time.Minute, "Maximum time to wait for the deployment informer cache to sync (0 waits forever)")
+	serverCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "Maximum time to drain in-flight requests and stop the controller on SIGINT or SIGTERM")
+	serverCmd.Flags().StringVar(&imagePolicyPath, "image-policy", "", "Path to a YAML image policy enforced by the deployment controller")
+	serverCmd.Flags().BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the admission webhooks")
+	serverCmd.Flags().IntVar(&webhookPort, "webhook-port", crwebhook.DefaultPort, "Port for the admission webhook server")
+	serverCmd.Flags().StringVar(&webhookCertDir, "webhook-cert-dir", "", "Directory containing tls.crt and tls.key for the webhook server (default <temp-dir>/k8s-webhook-server/serving-certs)")
+	serverCmd.Flags().StringVar(&validationRulesPath, "validation-rules", "", "Path to a YAML file with the Deployment validation webhook rules")
+	serverCmd.Flags().StringVar(&defaultingConfigPath, "defaulting-config", "", "Path to a YAML file with the defaults injected by the Deployment mutating webhook")
+	serverCmd.Flags().BoolVar(&controllerDryRun, "dry-run", false, "Compute and report the changes of the deployment controller without applying them")
+	serverCmd.Flags().IntVar(&controllerQueue.MaxConcurrentReconciles, "max-concurrent-reconciles", ctrl.DefaultMaxConcurrentReconciles, "Number of Deployments the deployment controller reconciles in parallel")
+	serverCmd.Flags().DurationVar(&controllerQueue.BaseBackoff, "reconcile-base-backoff", ctrl.DefaultBaseBackoff, "Delay before retrying a failed reconcile, doubled on every further failure")
+	serverCmd.Flags().DurationVar(&controllerQueue.MaxBackoff, "reconcile-max-backoff", ctrl.DefaultMaxBackoff, "Maximum delay between retries of a failing reconcile")
+	serverCmd.Flags().Float64Var(&controllerQueue.QPS, "reconcile-qps", ctrl.DefaultQPS, "Reconciles per second allowed across all Deployments")
+	serverCmd.Flags().IntVar(&controllerQueue.Burst, "reconcile-burst", ctrl.DefaultBurst, "Reconciles allowed in a burst above --reconcile-qps")
+	serverCmd.Flags().DurationVar(&controllerQueue.RequeueInterval, "requeue-interval", 0, "Reconcile every Deployment again after this interval, with 10% jitter (0 disables periodic reconciles)")
+	serverCmd.Flags().IntVar(&livenessResyncs, "liveness-resyncs", 10, "Number of informer resync periods without progress after which /healthz fails")
 }
@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/informer"
 	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	crcache "sigs.k8s.io/controller-runtime/pkg/cache"
 )
 
 // MockDeploymentLister is a mock for the DeploymentLister interface
@@ -19,57 +30,420 @@ type MockDeploymentLister struct {
 	mock.Mock
 }
 
-func (m *MockDeploymentLister) GetDeploymentsNames() []string {
-	args := m.Called()
-	return args.Get(0).([]string)
+func (m *MockDeploymentLister) List(namespace string) []*appsv1.Deployment {
+	args := m.Called(namespace)
+	return args.Get(0).([]*appsv1.Deployment)
+}
+
+func (m *MockDeploymentLister) Get(namespace, name string) (*appsv1.Deployment, bool) {
+	args := m.Called(namespace, name)
+	return args.Get(0).(*appsv1.Deployment), args.Bool(1)
+}
+
+func (m *MockDeploymentLister) ReplicaSets(deployment *appsv1.Deployment) []*appsv1.ReplicaSet {
+	args := m.Called(deployment)
+	return args.Get(0).([]*appsv1.ReplicaSet)
+}
+
+func (m *MockDeploymentLister) Watch(lastEventID string) *informer.Subscription {
+	args := m.Called(lastEventID)
+	return args.Get(0).(*informer.Subscription)
+}
+
+// doRequest runs the handler against a GET request for the given URI.
+func doRequest(handler fasthttp.RequestHandler, uri string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI(uri)
+	ctx.Request.Header.SetMethod("GET")
+	handler(ctx)
+	return ctx
 }
 
 func TestHandler_DeploymentsEndpoint(t *testing.T) {
+	created := metav1.NewTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              `deployment-"1"`,
+			Namespace:         "default",
+			Labels:            map[string]string{"app": "web"},
+			CreationTimestamp: created,
+		},
+		Spec: testutil.NewDeploymentSpec(3, map[string]string{"app": "web"}, "nginx:1.27"),
+		Status: appsv1.DeploymentStatus{
+			ReadyReplicas:     2,
+			AvailableReplicas: 2,
+			UpdatedReplicas:   3,
+			Conditions: []appsv1.DeploymentCondition{{
+				Type:               appsv1.DeploymentAvailable,
+				Status:             corev1.ConditionTrue,
+				Reason:             "MinimumReplicasAvailable",
+				LastTransitionTime: created,
+			}},
+		},
+	}
 	mockLister := new(MockDeploymentLister)
-	mockLister.On("GetDeploymentsNames").Return([]string{"deployment-1", "deployment-2"})
+	mockLister.On("List", "").Return([]*appsv1.Deployment{dep})
 
-	handler := createHandler(mockLister)
+	handler := createHandler(mockLister, healthChecks{})
+	ctx := doRequest(handler, "/deployments")
 
-	req := fasthttp.AcquireRequest()
-	defer fasthttp.ReleaseRequest(req)
-	req.SetRequestURI("/deployments")
-	req.Header.SetMethod("GET")
+	assert.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+
+	var got []informer.DeploymentSummary
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, `deployment-"1"`, got[0].Name)
+	assert.Equal(t, "default", got[0].Namespace)
+	assert.Equal(t, int32(3), got[0].DesiredReplicas)
+	assert.Equal(t, int32(2), got[0].ReadyReplicas)
+	assert.Equal(t, []string{"nginx:1.27"}, got[0].Images)
+	assert.Equal(t, created.UTC(), got[0].CreationTimestamp)
+	require.Len(t, got[0].Conditions, 1)
+	assert.Equal(t, "Available", got[0].Conditions[0].Type)
 
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseResponse(resp)
+	mockLister.AssertExpectations(t)
+}
 
-	ctx := &fasthttp.RequestCtx{
-		Request:  *req,
-		Response: *resp,
-	}
-	handler(ctx)
+func TestHandler_DeploymentsEndpointEmpty(t *testing.T) {
+	mockLister := new(MockDeploymentLister)
+	mockLister.On("List", "").Return([]*appsv1.Deployment(nil))
+
+	ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments")
 
 	assert.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "[]", string(ctx.Response.Body()))
+}
 
-	expectedBody := `["deployment-1","deployment-2"]`
-	assert.Equal(t, expectedBody, string(ctx.Response.Body()))
+func TestHandler_DeploymentsEndpointFilters(t *testing.T) {
+	newDeployment := func(namespace, name, team string, replicas, ready int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"team": team}},
+			Spec:       testutil.NewDeploymentSpec(replicas, map[string]string{"app": name}, "nginx:1.27"),
+			Status: appsv1.DeploymentStatus{
+				UpdatedReplicas:   replicas,
+				ReadyReplicas:     ready,
+				AvailableReplicas: ready,
+			},
+		}
+	}
+	deployments := []*appsv1.Deployment{
+		newDeployment("shop", "checkout", "payments", 2, 2),
+		newDeployment("shop", "billing", "payments", 2, 1),
+		newDeployment("default", "search", "search", 1, 0),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"labelSelector=team%3Dpayments", []string{"billing", "checkout"}},
+		{"labelSelector=team%3Dpayments&ready=false", []string{"billing"}},
+		{"namespace=default", []string{"search"}},
+		{"image=nginx", []string{"search", "billing", "checkout"}},
+		{"image=busybox", []string{}},
+		{"ready=true", []string{"checkout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			mockLister := new(MockDeploymentLister)
+			mockLister.On("List", mock.Anything).Return(deployments)
+
+			ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments?"+tt.query)
+
+			require.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+			var got []informer.DeploymentSummary
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+			names := []string{}
+			for _, s := range got {
+				names = append(names, s.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
 
+func TestHandler_DeploymentsEndpointInvalidQuery(t *testing.T) {
+	for _, query := range []string{
+		"labelSelector=team%3D%3D%3Dpayments",
+		"labelSelector=team+in+payments",
+		"ready=maybe",
+		"namespace=Not_Valid",
+	} {
+		t.Run(query, func(t *testing.T) {
+			mockLister := new(MockDeploymentLister)
+
+			ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments?"+query)
+
+			assert.Equal(t, http.StatusBadRequest, ctx.Response.StatusCode())
+			assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+			assert.NotEmpty(t, body["error"])
+			mockLister.AssertNotCalled(t, "List", mock.Anything)
+		})
+	}
+}
+
+func TestHandler_DeploymentsEndpointPagination(t *testing.T) {
+	var deployments []*appsv1.Deployment
+	for _, name := range []string{"e", "c", "a", "d", "b"} {
+		deployments = append(deployments, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		})
+	}
+	mockLister := new(MockDeploymentLister)
+	mockLister.On("List", "").Return(deployments)
+	handler := createHandler(mockLister, healthChecks{})
+
+	var names []string
+	uri := "/deployments?limit=2"
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3, "too many pages")
+		ctx := doRequest(handler, uri)
+		require.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+		var got []informer.DeploymentSummary
+		require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+		for _, s := range got {
+			names = append(names, s.Name)
+		}
+		next := string(ctx.Response.Header.Peek(continueHeader))
+		if next == "" {
+			break
+		}
+		uri = "/deployments?limit=2&continue=" + next
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names)
+}
+
+func TestHandler_DeploymentsEndpointSort(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newDeployment := func(name string, replicas int32, age time.Duration) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", CreationTimestamp: metav1.NewTime(base.Add(age))},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		}
+	}
+	deployments := []*appsv1.Deployment{
+		newDeployment("a", 3, 2*time.Hour),
+		newDeployment("b", 1, 0),
+		newDeployment("c", 2, time.Hour),
+	}
+
+	for query, want := range map[string][]string{
+		"":                       {"a", "b", "c"},
+		"sort=name":              {"a", "b", "c"},
+		"sort=creationTimestamp": {"b", "c", "a"},
+		"sort=replicas":          {"b", "c", "a"},
+	} {
+		t.Run(query, func(t *testing.T) {
+			mockLister := new(MockDeploymentLister)
+			mockLister.On("List", "").Return(append([]*appsv1.Deployment(nil), deployments...))
+
+			ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments?"+query)
+
+			require.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+			var got []informer.DeploymentSummary
+			require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+			var names []string
+			for _, s := range got {
+				names = append(names, s.Name)
+			}
+			assert.Equal(t, want, names)
+		})
+	}
+}
+
+func TestHandler_DeploymentsEndpointInvalidPagination(t *testing.T) {
+	for _, query := range []string{"sort=size", "limit=0", "limit=ten", "continue=%25%25%25"} {
+		t.Run(query, func(t *testing.T) {
+			mockLister := new(MockDeploymentLister)
+			mockLister.On("List", "").Return([]*appsv1.Deployment(nil)).Maybe()
+
+			ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments?"+query)
+
+			assert.Equal(t, http.StatusBadRequest, ctx.Response.StatusCode())
+			assert.Contains(t, string(ctx.Response.Body()), `"error"`)
+		})
+	}
+}
+
+func TestHandler_DeploymentDetailEndpoint(t *testing.T) {
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "dep-uid"},
+		Spec:       testutil.NewDeploymentSpec(2, map[string]string{"app": "web"}, "nginx:1.27"),
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 2},
+	}
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web-abc123",
+			Namespace:   "shop",
+			Annotations: map[string]string{"deployment.kubernetes.io/revision": "3"},
+		},
+	}
+	mockLister := new(MockDeploymentLister)
+	mockLister.On("Get", "shop", "web").Return(dep, true)
+	mockLister.On("ReplicaSets", dep).Return([]*appsv1.ReplicaSet{rs})
+
+	ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments/shop/web")
+
+	assert.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+	var got informer.DeploymentDetail
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &got))
+	assert.Equal(t, "web", got.Metadata.Name)
+	assert.Equal(t, int32(2), *got.Spec.Replicas)
+	assert.Equal(t, int32(2), got.Status.ReadyReplicas)
+	require.Len(t, got.ReplicaSets, 1)
+	assert.Equal(t, "web-abc123", got.ReplicaSets[0].Name)
+	assert.Equal(t, "3", got.ReplicaSets[0].Revision)
 	mockLister.AssertExpectations(t)
 }
 
-func TestHandler_UnknownEndpoint(t *testing.T) {
+func TestHandler_DeploymentDetailNotFound(t *testing.T) {
 	mockLister := new(MockDeploymentLister)
+	mockLister.On("Get", "default", "missing").Return((*appsv1.Deployment)(nil), false)
+
+	ctx := doRequest(createHandler(mockLister, healthChecks{}), "/deployments/default/missing")
+
+	assert.Equal(t, http.StatusNotFound, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"error":"deployment default/missing not found"}`, string(ctx.Response.Body()))
+}
+
+func TestHandler_DeploymentDetailMalformedPath(t *testing.T) {
+	for _, uri := range []string{
+		"/deployments/",
+		"/deployments/default",
+		"/deployments/default/",
+		"/deployments/default/web/extra",
+		"/deployments/Default/web",
+		"/deployments/default/web_1",
+	} {
+		t.Run(uri, func(t *testing.T) {
+			mockLister := new(MockDeploymentLister)
+
+			ctx := doRequest(createHandler(mockLister, healthChecks{}), uri)
+
+			assert.Equal(t, http.StatusBadRequest, ctx.Response.StatusCode())
+			assert.Contains(t, string(ctx.Response.Body()), `"error"`)
+			mockLister.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+		})
+	}
+}
 
-	handler := createHandler(mockLister)
+// openEventStream serves the handler on an in-memory listener and returns a reader for the response of uri.
+func openEventStream(t *testing.T, handler fasthttp.RequestHandler, uri string, header string) *bufio.Reader {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	srv := &fasthttp.Server{Handler: handler}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = ln.Close() })
 
-	req := fasthttp.AcquireRequest()
-	defer fasthttp.ReleaseRequest(req)
-	req.SetRequestURI("/unknown")
-	req.Header.SetMethod("GET")
+	conn, err := ln.Dial()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	_, err = fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: test\r\n%s\r\n", uri, header)
+	require.NoError(t, err)
 
-	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseResponse(resp)
+	r := bufio.NewReader(conn)
+	status, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Contains(t, status, "200 OK")
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if line == "\r\n" {
+			break
+		}
+	}
+	return r
+}
 
-	ctx := &fasthttp.RequestCtx{
-		Request:  *req,
-		Response: *resp,
+// readSSE reads the next event with a data field, skipping chunk framing, retry and heartbeat lines.
+func readSSE(t *testing.T, r *bufio.Reader) (id, event, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && data != "":
+			return id, event, data
+		}
 	}
-	handler(ctx)
+}
+
+func TestHandler_WatchEndpointSnapshotAndLiveEvents(t *testing.T) {
+	b := informer.NewBroadcaster(10)
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "5"}}
+	mockLister := new(MockDeploymentLister)
+	mockLister.On("Watch", "").Return(b.Subscribe(""))
+	mockLister.On("List", "").Return([]*appsv1.Deployment{dep})
+
+	r := openEventStream(t, createHandler(mockLister, healthChecks{}), "/deployments/watch", "")
+
+	id, event, data := readSSE(t, r)
+	assert.Empty(t, id, "snapshot events must not move the resume position")
+	assert.Equal(t, "ADDED", event)
+	assert.Contains(t, data, `"name":"web"`)
+
+	b.Publish(informer.DeploymentEvent{Type: informer.EventDeleted, ResourceVersion: "6", Deployment: informer.DeploymentSummary{Name: "web"}})
+
+	id, event, data = readSSE(t, r)
+	assert.NotEmpty(t, id)
+	assert.Equal(t, "DELETED", event)
+	var got informer.DeploymentEvent
+	require.NoError(t, json.Unmarshal([]byte(data), &got))
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, informer.EventDeleted, got.Type)
+	assert.Equal(t, "6", got.ResourceVersion)
+	assert.Equal(t, "web", got.Deployment.Name)
+}
+
+func TestHandler_WatchEndpointResume(t *testing.T) {
+	b := informer.NewBroadcaster(10)
+	live := b.Subscribe("")
+	var ids []string
+	for _, rv := range []string{"1", "2", "3"} {
+		b.Publish(informer.DeploymentEvent{Type: informer.EventModified, ResourceVersion: rv})
+		ids = append(ids, (<-live.Events).ID)
+	}
+	mockLister := new(MockDeploymentLister)
+	mockLister.On("Watch", ids[0]).Return(b.Subscribe(ids[0]))
+
+	r := openEventStream(t, createHandler(mockLister, healthChecks{}), "/deployments/watch", "Last-Event-ID: "+ids[0]+"\r\n")
+
+	id, _, data := readSSE(t, r)
+	assert.Equal(t, ids[1], id)
+	assert.Contains(t, data, `"resourceVersion":"2"`)
+	id, _, _ = readSSE(t, r)
+	assert.Equal(t, ids[2], id)
+	mockLister.AssertNotCalled(t, "List", mock.Anything)
+}
+
+func TestHandler_WatchEndpointExpiredEventID(t *testing.T) {
+	b := informer.NewBroadcaster(10)
+	mockLister := new(MockDeploymentLister)
+	mockLister.On("Watch", "99").Return(b.Subscribe("99"))
+	mockLister.On("List", "").Return([]*appsv1.Deployment(nil))
+
+	r := openEventStream(t, createHandler(mockLister, healthChecks{}), "/deployments/watch?lastEventId=99", "")
+
+	_, event, data := readSSE(t, r)
+	assert.Equal(t, "RESET", event)
+	assert.JSONEq(t, `{"lastEventId":"99"}`, data)
+}
+
+func TestHandler_UnknownEndpoint(t *testing.T) {
+	mockLister := new(MockDeploymentLister)
+
+	ctx := doRequest(createHandler(mockLister, healthChecks{}), "/unknown")
 
 	assert.Equal(t, http.StatusOK, ctx.Response.StatusCode())
 
@@ -77,6 +451,105 @@ func TestHandler_UnknownEndpoint(t *testing.T) {
 	assert.Equal(t, expectedBody, string(ctx.Response.Body()))
 }
 
+func TestWatchedNamespaces(t *testing.T) {
+	assert.Equal(t, []string{"default"}, watchedNamespaces([]string{"default"}, false))
+	assert.Equal(t, []string{"a", "b"}, watchedNamespaces([]string{"a", "b", "a"}, false))
+	assert.Equal(t, []string{""}, watchedNamespaces([]string{"a"}, true))
+	assert.Equal(t, []string{""}, watchedNamespaces(nil, false))
+}
+
+func TestDeploymentSelection(t *testing.T) {
+	sel, err := deploymentSelection([]string{"team-a"}, []string{"team-a-canary"}, "tier in (frontend,api)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team-a"}, sel.Namespaces)
+	assert.Equal(t, []string{"team-a-canary"}, sel.ExcludedNamespaces)
+	assert.Equal(t, "tier in (api,frontend)", sel.Selector.String())
+
+	sel, err = deploymentSelection([]string{""}, nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, sel.Selector)
+
+	_, err = deploymentSelection(nil, nil, "tier in frontend")
+	assert.ErrorContains(t, err, "invalid --selector")
+}
+
+func TestManagerCacheOptions(t *testing.T) {
+	sel, err := deploymentSelection([]string{"team-a", "team-b"}, []string{"team-a-canary"}, "tier=frontend")
+	require.NoError(t, err)
+	opts := managerCacheOptions(sel)
+	assert.Equal(t, map[string]crcache.Config{"team-a": {}, "team-b": {}}, opts.DefaultNamespaces)
+	assert.Equal(t, "metadata.namespace!=team-a-canary", opts.DefaultFieldSelector.String())
+	for obj, byObject := range opts.ByObject {
+		assert.IsType(t, &appsv1.Deployment{}, obj)
+		assert.Equal(t, "tier=frontend", byObject.Label.String())
+	}
+
+	sel, err = deploymentSelection(watchedNamespaces(nil, false), nil, "")
+	require.NoError(t, err)
+	opts = managerCacheOptions(sel)
+	assert.Nil(t, opts.DefaultNamespaces, "all namespaces are cached")
+	assert.True(t, opts.DefaultFieldSelector.Empty())
+}
+
+func TestRunServer_ReturnsClientError(t *testing.T) {
+	origServerKubeConfig := serverKubeConfig
+	origServerInCluster := serverInCluster
+	defer func() {
+		serverKubeConfig = origServerKubeConfig
+		serverInCluster = origServerInCluster
+	}()
+	serverKubeConfig = "/nonexistent/kubeconfig"
+	serverInCluster = false
+
+	err := runServer(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create kubernetes client")
+}
+
+func TestShutdownHTTPServer_DrainsInFlightRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		delay   time.Duration
+		timeout time.Duration
+		wantErr error
+	}{
+		{"drained", 100 * time.Millisecond, 5 * time.Second, nil},
+		{"timed out", 5 * time.Second, 100 * time.Millisecond, errShutdownTimeout},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			ln := fasthttputil.NewInmemoryListener()
+			srv := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+				close(started)
+				time.Sleep(tt.delay)
+				ctx.SetBodyString("done")
+			}}
+			go func() { _ = srv.Serve(ln) }()
+
+			conn, err := ln.Dial()
+			require.NoError(t, err)
+			defer conn.Close()
+			_, err = fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+			require.NoError(t, err)
+			<-started
+
+			err = shutdownHTTPServer(srv, ln, tt.timeout)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, exitShutdownTimeout, exitCode(err))
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, exitFailure, exitCode(errors.New("boom")))
+	assert.Equal(t, exitShutdownTimeout, exitCode(fmt.Errorf("server: %w", errShutdownTimeout)))
+}
+
 func TestGetServerKubeClient(t *testing.T) {
 	// Тест с использованием envtest для проверки создания клиента
 	_, _, cleanup := testutil.SetupEnv(t)
@@ -0,0 +1,66 @@
+package v1alpha1
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+func TestAddToScheme(t *testing.T) {
+	s := runtime.NewScheme()
+	require.NoError(t, AddToScheme(s))
+
+	gvks, _, err := s.ObjectKinds(&Application{})
+	require.NoError(t, err)
+	require.Equal(t, GroupVersion.WithKind("Application"), gvks[0])
+	require.True(t, s.Recognizes(GroupVersion.WithKind("ApplicationList")))
+}
+
+func TestApplication_DeepCopy(t *testing.T) {
+	replicas := int32(2)
+	app := &Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "a"}},
+		Spec:       ApplicationSpec{Image: "nginx", Replicas: &replicas, Env: []EnvVar{{Name: "A", Value: "1"}}},
+		Status:     ApplicationStatus{Conditions: []metav1.Condition{{Type: ConditionReady}}},
+	}
+
+	out := app.DeepCopyObject().(*Application)
+	*out.Spec.Replicas = 3
+	out.Spec.Env[0].Value = "2"
+	out.Labels["team"] = "b"
+	out.Status.Conditions[0].Reason = "Changed"
+
+	require.Equal(t, int32(2), *app.Spec.Replicas)
+	require.Equal(t, "1", app.Spec.Env[0].Value)
+	require.Equal(t, "a", app.Labels["team"])
+	require.Empty(t, app.Status.Conditions[0].Reason)
+}
+
+func TestCRDManifestMatchesTypes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "..", "chart", "app", "crds", "tutorial.io_applications.yaml"))
+	require.NoError(t, err)
+	var crd struct {
+		Spec struct {
+			Group string `json:"group"`
+			Names struct {
+				Kind     string `json:"kind"`
+				ListKind string `json:"listKind"`
+			} `json:"names"`
+			Versions []struct {
+				Name string `json:"name"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	require.NoError(t, yaml.Unmarshal(data, &crd))
+
+	require.Equal(t, GroupVersion.Group, crd.Spec.Group)
+	require.Equal(t, "Application", crd.Spec.Names.Kind)
+	require.Equal(t, "ApplicationList", crd.Spec.Names.ListKind)
+	require.Len(t, crd.Spec.Versions, 1)
+	require.Equal(t, GroupVersion.Version, crd.Spec.Versions[0].Name)
+}
@@ -0,0 +1,254 @@
+package ctrl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/api/v1alpha1"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
+)
+
+// applicationControllerName is used for the controller and as the source of recorded events.
+const applicationControllerName = "application-controller"
+
+// Labels set on the resources generated for an Application.
+const (
+	appNameLabel   = "app.kubernetes.io/name"
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "k8s-controller-tutorial"
+)
+
+// Event reasons recorded on Applications.
+const (
+	ReasonApplicationResourceSynced = "ResourceSynced"
+	ReasonApplicationSyncFailed     = "SyncFailed"
+	ReasonApplicationStatusFailed   = "StatusUpdateFailed"
+)
+
+// ApplicationReconciler runs each Application as a Deployment and a Service of
+// the same name. Both are owned by the Application, so deleting it lets the
+// garbage collector remove them.
+type ApplicationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder *events.Recorder
+	// Selection limits the Applications that are reconciled. Their labels are
+	// copied to the Deployment, so it is selected along with the Application.
+	Selection selection.Config
+}
+
+func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log.Info().Msgf("Reconciling Application: %s/%s", req.Namespace, req.Name)
+
+	app := &v1alpha1.Application{}
+	if err := r.Get(ctx, req.NamespacedName, app); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !app.DeletionTimestamp.IsZero() || !r.Selection.Matches(app) {
+		return ctrl.Result{}, nil
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	if err := r.sync(ctx, app, "Deployment", deployment, func() error { return r.mutateDeployment(app, deployment) }); err != nil {
+		return ctrl.Result{}, err
+	}
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	if err := r.sync(ctx, app, "Service", service, func() error { return r.mutateService(app, service) }); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, r.updateStatus(ctx, app, deployment)
+}
+
+// sync creates or updates an owned object and records an event when it changed.
+// Existing objects that the Application does not control are left alone.
+func (r *ApplicationReconciler) sync(ctx context.Context, app *v1alpha1.Application, kind string, obj client.Object, mutate controllerutil.MutateFn) error {
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+		if obj.GetResourceVersion() != "" && !metav1.IsControlledBy(obj, app) {
+			return fmt.Errorf("%s already exists and is not managed by this Application", kind)
+		}
+		return mutate()
+	})
+	if err != nil {
+		r.Recorder.Warningf(app, ReasonApplicationSyncFailed, "Failed to sync %s %s: %v", kind, obj.GetName(), err)
+		return fmt.Errorf("failed to sync %s %s/%s: %w", kind, obj.GetNamespace(), obj.GetName(), err)
+	}
+	if result != controllerutil.OperationResultNone {
+		r.Recorder.Normalf(app, ReasonApplicationResourceSynced, "%s %s %s", kind, obj.GetName(), result)
+	}
+	return nil
+}
+
+// mutateDeployment sets the fields of the Deployment owned by the Application,
+// leaving the fields other controllers and the API server default untouched.
+func (r *ApplicationReconciler) mutateDeployment(app *v1alpha1.Application, deployment *appsv1.Deployment) error {
+	labels := applicationLabels(app)
+	deployment.Labels = mergeLabels(mergeLabels(deployment.Labels, app.Labels), labels)
+	if deployment.Spec.Selector == nil {
+		// The selector is immutable, so it is only set on creation.
+		deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{appNameLabel: app.Name}}
+	}
+	deployment.Spec.Replicas = applicationReplicas(app, deployment)
+
+	template := &deployment.Spec.Template
+	template.Labels = mergeLabels(template.Labels, labels)
+	container := corev1.Container{Name: "app"}
+	if len(template.Spec.Containers) > 0 {
+		container = template.Spec.Containers[0]
+	}
+	container.Image = app.Spec.Image
+	container.Ports = []corev1.ContainerPort{{Name: "http", ContainerPort: applicationPort(app), Protocol: corev1.ProtocolTCP}}
+	container.Env = nil
+	for _, env := range app.Spec.Env {
+		container.Env = append(container.Env, corev1.EnvVar{Name: env.Name, Value: env.Value})
+	}
+	mergeResources(&container.Resources, app.Spec.Resources)
+	template.Spec.Containers = []corev1.Container{container}
+	return controllerutil.SetControllerReference(app, deployment, r.Scheme)
+}
+
+// applicationReplicas returns the replicas of the Deployment, honouring the
+// policies of the deployment controller so that the two controllers do not
+// scale the Deployment back and forth: the replicas are kept within the
+// replica bounds, and a Deployment scaled to zero by the image policy stays
+// there, remembering the Application's replicas to restore once it complies.
+func applicationReplicas(app *v1alpha1.Application, deployment *appsv1.Deployment) *int32 {
+	replicas := int32(1)
+	if app.Spec.Replicas != nil {
+		replicas = *app.Spec.Replicas
+	}
+	if bounds, err := parseReplicaBounds(deployment.Annotations); err == nil {
+		replicas, _ = bounds.clamp(replicas)
+	}
+	if _, scaled := deployment.Annotations[ImagePolicyScaledFromAnnotation]; scaled {
+		setAnnotation(deployment, ImagePolicyScaledFromAnnotation, strconv.Itoa(int(replicas)))
+		replicas = 0
+	}
+	return &replicas
+}
+
+func (r *ApplicationReconciler) mutateService(app *v1alpha1.Application, service *corev1.Service) error {
+	service.Labels = mergeLabels(service.Labels, applicationLabels(app))
+	service.Spec.Selector = map[string]string{appNameLabel: app.Name}
+	port := applicationPort(app)
+	service.Spec.Ports = []corev1.ServicePort{{
+		Name:       "http",
+		Port:       port,
+		TargetPort: intstr.FromString("http"),
+		Protocol:   corev1.ProtocolTCP,
+	}}
+	return controllerutil.SetControllerReference(app, service, r.Scheme)
+}
+
+// updateStatus copies the Deployment's replica counts to the Application and sets its Ready condition.
+func (r *ApplicationReconciler) updateStatus(ctx context.Context, app *v1alpha1.Application, deployment *appsv1.Deployment) error {
+	status := app.Status.DeepCopy()
+	status.ObservedGeneration = app.Generation
+	status.Replicas = deployment.Status.Replicas
+	status.ReadyReplicas = deployment.Status.ReadyReplicas
+	status.AvailableReplicas = deployment.Status.AvailableReplicas
+
+	ready := metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             "RolloutInProgress",
+		Message:            fmt.Sprintf("%d of %d replicas are updated and ready", deployment.Status.ReadyReplicas, *deployment.Spec.Replicas),
+		ObservedGeneration: app.Generation,
+	}
+	if deploymentRolledOut(deployment) {
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = "RolloutComplete"
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+
+	if equality.Semantic.DeepEqual(*status, app.Status) {
+		return nil
+	}
+	patch := client.MergeFrom(app.DeepCopy())
+	app.Status = *status
+	if err := r.Status().Patch(ctx, app, patch); err != nil {
+		r.Recorder.Warningf(app, ReasonApplicationStatusFailed, "Failed to update status: %v", err)
+		return fmt.Errorf("failed to update status of Application %s/%s: %w", app.Namespace, app.Name, err)
+	}
+	return nil
+}
+
+// deploymentRolledOut reports whether the Deployment controller has observed the
+// latest spec and every desired replica is updated and ready.
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	desired := desiredReplicas(deployment)
+	s := deployment.Status
+	return s.ObservedGeneration >= deployment.Generation &&
+		s.UpdatedReplicas == desired && s.ReadyReplicas == desired && s.Replicas == desired
+}
+
+func applicationLabels(app *v1alpha1.Application) map[string]string {
+	return map[string]string{appNameLabel: app.Name, managedByLabel: managedByValue}
+}
+
+func applicationPort(app *v1alpha1.Application) int32 {
+	if app.Spec.Port == 0 {
+		return 8080
+	}
+	return app.Spec.Port
+}
+
+// mergeResources sets the requests and limits the Application specifies and
+// keeps the others, e.g. requests filled in by the defaulting webhook, so that
+// they are not removed and defaulted again on every reconcile.
+func mergeResources(resources *corev1.ResourceRequirements, app corev1.ResourceRequirements) {
+	if len(app.Requests) > 0 && resources.Requests == nil {
+		resources.Requests = corev1.ResourceList{}
+	}
+	for name, quantity := range app.Requests {
+		resources.Requests[name] = quantity
+	}
+	if len(app.Limits) > 0 && resources.Limits == nil {
+		resources.Limits = corev1.ResourceList{}
+	}
+	for name, quantity := range app.Limits {
+		resources.Limits[name] = quantity
+	}
+}
+
+func mergeLabels(existing, labels map[string]string) map[string]string {
+	if existing == nil {
+		existing = map[string]string{}
+	}
+	for k, v := range labels {
+		existing[k] = v
+	}
+	return existing
+}
+
+// AddApplicationController registers the Application controller for the
+// selected Applications. The Application CRD must be installed and its types
+// added to the manager's scheme.
+func AddApplicationController(mgr manager.Manager, sel selection.Config) error {
+	r := &ApplicationReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  events.NewRecorder(mgr.GetEventRecorderFor(applicationControllerName)),
+		Selection: sel,
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Application{}, builder.WithPredicates(sel.Predicate())).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Complete(r)
+}
@@ -0,0 +1,250 @@
+package ctrl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/api/v1alpha1"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
+)
+
+func newApplicationReconciler(t *testing.T, objs ...client.Object) (*ApplicationReconciler, *record.FakeRecorder) {
+	t.Helper()
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	c := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).WithStatusSubresource(&v1alpha1.Application{}).Build()
+	recorder := record.NewFakeRecorder(10)
+	return &ApplicationReconciler{Client: c, Scheme: s, Recorder: events.NewRecorder(recorder)}, recorder
+}
+
+func newApplication() *v1alpha1.Application {
+	return &v1alpha1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default", UID: "app-uid", Generation: 1},
+		Spec: v1alpha1.ApplicationSpec{
+			Image:    "ghcr.io/acme/shop:1.0.0",
+			Replicas: ptr.To[int32](2),
+			Port:     9090,
+			Env:      []v1alpha1.EnvVar{{Name: "MODE", Value: "prod"}},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+			},
+		},
+	}
+}
+
+func reconcileApplication(t *testing.T, r *ApplicationReconciler) {
+	t.Helper()
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "shop"}})
+	require.NoError(t, err)
+}
+
+func TestApplicationReconciler_CreatesOwnedResources(t *testing.T) {
+	ctx := context.Background()
+	r, recorder := newApplicationReconciler(t, newApplication())
+
+	reconcileApplication(t, r)
+
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, key, deployment))
+	require.Equal(t, int32(2), *deployment.Spec.Replicas)
+	require.Equal(t, map[string]string{appNameLabel: "shop"}, deployment.Spec.Selector.MatchLabels)
+	require.Equal(t, "shop", deployment.Spec.Template.Labels[appNameLabel])
+	container := deployment.Spec.Template.Spec.Containers[0]
+	require.Equal(t, "ghcr.io/acme/shop:1.0.0", container.Image)
+	require.Equal(t, int32(9090), container.Ports[0].ContainerPort)
+	require.Equal(t, []corev1.EnvVar{{Name: "MODE", Value: "prod"}}, container.Env)
+	require.Equal(t, "100m", container.Resources.Requests.Cpu().String())
+	require.True(t, metav1.IsControlledBy(deployment, newApplication()))
+
+	service := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, key, service))
+	require.Equal(t, map[string]string{appNameLabel: "shop"}, service.Spec.Selector)
+	require.Equal(t, int32(9090), service.Spec.Ports[0].Port)
+	require.Equal(t, "http", service.Spec.Ports[0].TargetPort.String())
+	require.True(t, metav1.IsControlledBy(service, newApplication()))
+
+	require.Equal(t, "Normal ResourceSynced Deployment shop created", <-recorder.Events)
+	require.Equal(t, "Normal ResourceSynced Service shop created", <-recorder.Events)
+
+	app := &v1alpha1.Application{}
+	require.NoError(t, r.Get(ctx, key, app))
+	require.Equal(t, int64(1), app.Status.ObservedGeneration)
+	require.True(t, meta.IsStatusConditionFalse(app.Status.Conditions, v1alpha1.ConditionReady))
+}
+
+func TestApplicationReconciler_UpdatesAndPropagatesStatus(t *testing.T) {
+	ctx := context.Background()
+	r, recorder := newApplicationReconciler(t, newApplication())
+	reconcileApplication(t, r)
+	<-recorder.Events
+	<-recorder.Events
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+
+	app := &v1alpha1.Application{}
+	require.NoError(t, r.Get(ctx, key, app))
+	app.Spec.Image = "ghcr.io/acme/shop:1.1.0"
+	require.NoError(t, r.Update(ctx, app))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, key, deployment))
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2}
+	require.NoError(t, r.Status().Update(ctx, deployment))
+
+	reconcileApplication(t, r)
+
+	require.NoError(t, r.Get(ctx, key, deployment))
+	require.Equal(t, "ghcr.io/acme/shop:1.1.0", deployment.Spec.Template.Spec.Containers[0].Image)
+	require.Equal(t, "Normal ResourceSynced Deployment shop updated", <-recorder.Events)
+	require.Empty(t, recorder.Events, "the unchanged Service is not updated")
+
+	require.NoError(t, r.Get(ctx, key, app))
+	require.Equal(t, int32(2), app.Status.ReadyReplicas)
+	require.Equal(t, int32(2), app.Status.AvailableReplicas)
+	require.True(t, meta.IsStatusConditionTrue(app.Status.Conditions, v1alpha1.ConditionReady))
+}
+
+func TestApplicationReconciler_KeepsDefaultedResources(t *testing.T) {
+	ctx := context.Background()
+	r, recorder := newApplicationReconciler(t, newApplication())
+	reconcileApplication(t, r)
+	<-recorder.Events
+	<-recorder.Events
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+
+	// The defaulting webhook fills in the requests the Application leaves out.
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, key, deployment))
+	deployment.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory] = resource.MustParse("128Mi")
+	require.NoError(t, r.Update(ctx, deployment))
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 2}
+	require.NoError(t, r.Status().Update(ctx, deployment))
+
+	app := &v1alpha1.Application{}
+	require.NoError(t, r.Get(ctx, key, app))
+	deployment = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: app.Name, Namespace: app.Namespace}}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error { return r.mutateDeployment(app, deployment) })
+	require.NoError(t, err)
+	require.Equal(t, controllerutil.OperationResultNone, result)
+	requests := deployment.Spec.Template.Spec.Containers[0].Resources.Requests
+	require.Equal(t, "100m", requests.Cpu().String())
+	require.Equal(t, "128Mi", requests.Memory().String())
+
+	reconcileApplication(t, r)
+	require.Empty(t, recorder.Events, "nothing is synced again")
+}
+
+func TestApplicationReconciler_DoesNotAdoptExistingDeployment(t *testing.T) {
+	existing := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default"}}
+	r, recorder := newApplicationReconciler(t, newApplication(), existing)
+
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "shop"}})
+
+	require.ErrorContains(t, err, "Deployment already exists and is not managed by this Application")
+	require.Contains(t, <-recorder.Events, "Warning SyncFailed Failed to sync Deployment shop")
+}
+
+func TestApplicationReconciler_Selection(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+	team := labels.SelectorFromSet(labels.Set{"team": "payments"})
+
+	// Unselected Applications are left alone.
+	r, _ := newApplicationReconciler(t, newApplication())
+	r.Selection = selection.Config{Selector: team}
+	reconcileApplication(t, r)
+	require.True(t, apierrors.IsNotFound(r.Get(ctx, key, &appsv1.Deployment{})))
+
+	// The labels of a selected Application are copied to its Deployment, so
+	// that the Deployment is in the label-filtered cache as well.
+	app := newApplication()
+	app.Labels = map[string]string{"team": "payments"}
+	r, _ = newApplicationReconciler(t, app)
+	r.Selection = selection.Config{Selector: team}
+	reconcileApplication(t, r)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, key, deployment))
+	require.True(t, r.Selection.Matches(deployment))
+	require.Equal(t, managedByValue, deployment.Labels[managedByLabel])
+}
+
+func TestApplicationReconciler_HonoursDeploymentPolicies(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "default", Name: "shop"}
+	app := newApplication()
+	app.Spec.Image = "ghcr.io/acme/shop:latest"
+	r, _ := newApplicationReconciler(t, app)
+	deployments := &DeploymentReconciler{
+		Client:      r.Client,
+		Scheme:      r.Scheme,
+		Recorder:    events.NewRecorder(&record.FakeRecorder{}),
+		ImagePolicy: &ImagePolicy{Mode: ImagePolicyEnforce, DisallowLatestTag: true},
+	}
+	reconcileBoth := func() *appsv1.Deployment {
+		t.Helper()
+		reconcileApplication(t, r)
+		_, err := deployments.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		require.NoError(t, err)
+		deployment := &appsv1.Deployment{}
+		require.NoError(t, r.Get(ctx, key, deployment))
+		return deployment
+	}
+	// settled fails when another round of both controllers still changes the Deployment.
+	settled := func(deployment *appsv1.Deployment) {
+		t.Helper()
+		require.Equal(t, deployment.ResourceVersion, reconcileBoth().ResourceVersion, "the controllers keep changing the Deployment")
+	}
+
+	deployment := reconcileBoth()
+	require.Equal(t, int32(0), *deployment.Spec.Replicas)
+	require.Equal(t, "2", deployment.Annotations[ImagePolicyScaledFromAnnotation])
+	settled(deployment)
+
+	// Scaling a blocked Application only changes the replicas restored later.
+	require.NoError(t, r.Get(ctx, key, app))
+	app.Spec.Replicas = ptr.To[int32](3)
+	require.NoError(t, r.Update(ctx, app))
+	deployment = reconcileBoth()
+	require.Equal(t, int32(0), *deployment.Spec.Replicas)
+	require.Equal(t, "3", deployment.Annotations[ImagePolicyScaledFromAnnotation])
+	settled(deployment)
+
+	require.NoError(t, r.Get(ctx, key, app))
+	app.Spec.Image = "ghcr.io/acme/shop:1.0.1"
+	require.NoError(t, r.Update(ctx, app))
+	deployment = reconcileBoth()
+	require.Equal(t, int32(3), *deployment.Spec.Replicas)
+	require.NotContains(t, deployment.Annotations, ImagePolicyScaledFromAnnotation)
+	settled(deployment)
+
+	setAnnotation(deployment, MaxReplicasAnnotation, "2")
+	require.NoError(t, r.Update(ctx, deployment))
+	deployment = reconcileBoth()
+	require.Equal(t, int32(2), *deployment.Spec.Replicas)
+	settled(deployment)
+}
+
+func TestApplicationReconciler_NotFound(t *testing.T) {
+	r, _ := newApplicationReconciler(t)
+	reconcileApplication(t, r)
+}
@@ -0,0 +1,219 @@
+package ctrl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// RestartOnConfigChangeAnnotation opts a Deployment in to rollouts when its ConfigMaps or Secrets change.
+	RestartOnConfigChangeAnnotation = "tutorial.io/restart-on-config-change"
+	// ConfigHashAnnotation is stamped on the pod template with a hash of the referenced configuration.
+	ConfigHashAnnotation = "tutorial.io/config-hash"
+)
+
+// Field indexes of opted-in Deployments by the ConfigMaps and Secrets their pod template references.
+const (
+	configMapIndexField = "spec.template.configMapRefs"
+	secretIndexField    = "spec.template.secretRefs"
+)
+
+// configRefs are the names of the ConfigMaps and Secrets a pod template references, sorted and deduplicated.
+type configRefs struct {
+	configMaps []string
+	secrets    []string
+}
+
+// referencedConfig collects the ConfigMaps and Secrets used by env, envFrom and volumes of a pod spec.
+func referencedConfig(spec *corev1.PodSpec) configRefs {
+	configMaps, secrets := map[string]bool{}, map[string]bool{}
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, c := range containers {
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				configMaps[ref.Name] = true
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				secrets[ref.Name] = true
+			}
+		}
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				configMaps[from.ConfigMapRef.Name] = true
+			}
+			if from.SecretRef != nil {
+				secrets[from.SecretRef.Name] = true
+			}
+		}
+	}
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			configMaps[v.ConfigMap.Name] = true
+		}
+		if v.Secret != nil {
+			secrets[v.Secret.SecretName] = true
+		}
+		if v.Projected != nil {
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps[source.ConfigMap.Name] = true
+				}
+				if source.Secret != nil {
+					secrets[source.Secret.Name] = true
+				}
+			}
+		}
+	}
+	return configRefs{configMaps: sortedKeys(configMaps), secrets: sortedKeys(secrets)}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for k := range set {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func restartOnConfigChange(deployment *appsv1.Deployment) bool {
+	return deployment.Annotations[RestartOnConfigChangeAnnotation] == "true"
+}
+
+// indexConfigRefs returns the index function for configMapIndexField or secretIndexField.
+// Only opted-in Deployments are indexed, so configuration changes never enqueue the others.
+func indexConfigRefs(field string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		deployment, ok := obj.(*appsv1.Deployment)
+		if !ok || !restartOnConfigChange(deployment) {
+			return nil
+		}
+		refs := referencedConfig(&deployment.Spec.Template.Spec)
+		if field == configMapIndexField {
+			return refs.configMaps
+		}
+		return refs.secrets
+	}
+}
+
+// deploymentsReferencing maps a ConfigMap or Secret to the opted-in Deployments in its namespace that reference it.
+func deploymentsReferencing(c client.Reader, field string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		deployments := &appsv1.DeploymentList{}
+		if err := c.List(ctx, deployments, client.InNamespace(obj.GetNamespace()), client.MatchingFields{field: obj.GetName()}); err != nil {
+			log.Error().Err(err).Msgf("Failed to list Deployments referencing %s/%s", obj.GetNamespace(), obj.GetName())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(deployments.Items))
+		for _, d := range deployments.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: d.Namespace, Name: d.Name}})
+		}
+		return requests
+	}
+}
+
+// configHash hashes the content of every referenced ConfigMap and Secret. Missing
+// objects are part of the hash, so creating one that the pods wait for triggers a rollout too.
+// Secrets are read with their own reader, so that their data need not be cached.
+func configHash(ctx context.Context, c, secrets client.Reader, namespace string, refs configRefs) (string, error) {
+	h := sha256.New()
+	for _, name := range refs.configMaps {
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cm); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to get ConfigMap %s/%s: %w", namespace, name, err)
+			}
+			fmt.Fprintf(h, "configmap/%s missing\n", name)
+			continue
+		}
+		fmt.Fprintf(h, "configmap/%s\n", name)
+		hashData(h, cm.Data)
+		hashBinaryData(h, cm.BinaryData)
+	}
+	for _, name := range refs.secrets {
+		secret := &corev1.Secret{}
+		if err := secrets.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to get Secret %s/%s: %w", namespace, name, err)
+			}
+			fmt.Fprintf(h, "secret/%s missing\n", name)
+			continue
+		}
+		fmt.Fprintf(h, "secret/%s\n", name)
+		hashBinaryData(h, secret.Data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+func hashData(w io.Writer, data map[string]string) {
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		fmt.Fprintf(w, "%s=%q\n", k, data[k])
+	}
+}
+
+func hashBinaryData(w io.Writer, data map[string][]byte) {
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		fmt.Fprintf(w, "%s=%x\n", k, data[k])
+	}
+}
+
+// stampConfigHash updates the pod template's config hash of an opted-in Deployment.
+// It returns a description of the change, or an empty string when the hash is current.
+func (r *DeploymentReconciler) stampConfigHash(ctx context.Context, deployment *appsv1.Deployment) (string, error) {
+	if !restartOnConfigChange(deployment) {
+		return "", nil
+	}
+	refs := referencedConfig(&deployment.Spec.Template.Spec)
+	secrets := r.APIReader
+	if secrets == nil {
+		secrets = r.Client
+	}
+	hash, err := configHash(ctx, r.Client, secrets, deployment.Namespace, refs)
+	if err != nil {
+		return "", err
+	}
+	previous := deployment.Spec.Template.Annotations[ConfigHashAnnotation]
+	if hash == previous {
+		return "", nil
+	}
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[ConfigHashAnnotation] = hash
+
+	names := []string{}
+	for _, name := range refs.configMaps {
+		names = append(names, "configmap/"+name)
+	}
+	for _, name := range refs.secrets {
+		names = append(names, "secret/"+name)
+	}
+	if len(names) == 0 {
+		names = append(names, "(none)")
+	}
+	if previous == "" {
+		return fmt.Sprintf("Tracking configuration %s with hash %s", strings.Join(names, ", "), hash), nil
+	}
+	return fmt.Sprintf("Configuration %s changed, rolling out pods with hash %s", strings.Join(names, ", "), hash), nil
+}
@@ -0,0 +1,198 @@
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
+)
+
+func newConfigDeployment(optIn bool) *appsv1.Deployment {
+	d := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	if optIn {
+		d.Annotations = map[string]string{RestartOnConfigChangeAnnotation: "true"}
+	}
+	spec := &d.Spec.Template.Spec
+	spec.Containers[0].EnvFrom = []corev1.EnvFromSource{
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}}},
+	}
+	spec.Volumes = []corev1.Volume{
+		{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls"}}},
+	}
+	return d
+}
+
+func TestReferencedConfig(t *testing.T) {
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{
+			Env: []corev1.EnvVar{{Name: "TOKEN", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}, Key: "token"},
+			}}},
+		}},
+		Containers: []corev1.Container{{
+			Env: []corev1.EnvVar{
+				{Name: "PLAIN", Value: "x"},
+				{Name: "MODE", ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}, Key: "mode"},
+				}},
+			},
+			EnvFrom: []corev1.EnvFromSource{
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}},
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "db"}}},
+			},
+		}},
+		Volumes: []corev1.Volume{
+			{Name: "a", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "files"}}}},
+			{Name: "b", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: []corev1.VolumeProjection{
+				{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "ca"}}},
+				{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "tls"}}},
+			}}}},
+			{Name: "c", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+	}
+
+	refs := referencedConfig(spec)
+
+	require.Equal(t, []string{"ca", "files", "settings"}, refs.configMaps)
+	require.Equal(t, []string{"db", "tls", "token"}, refs.secrets)
+}
+
+func TestIndexConfigRefs(t *testing.T) {
+	require.Equal(t, []string{"app-config"}, indexConfigRefs(configMapIndexField)(newConfigDeployment(true)))
+	require.Equal(t, []string{"tls"}, indexConfigRefs(secretIndexField)(newConfigDeployment(true)))
+	require.Nil(t, indexConfigRefs(configMapIndexField)(newConfigDeployment(false)))
+}
+
+func newConfigClient(objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).
+		WithIndex(&appsv1.Deployment{}, configMapIndexField, indexConfigRefs(configMapIndexField)).
+		WithIndex(&appsv1.Deployment{}, secretIndexField, indexConfigRefs(secretIndexField)).
+		Build()
+}
+
+func TestDeploymentsReferencing(t *testing.T) {
+	other := newConfigDeployment(false)
+	other.Name = "other"
+	c := newConfigClient(newConfigDeployment(true), other)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}}
+
+	requests := deploymentsReferencing(c, configMapIndexField)(context.Background(), cm)
+
+	require.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}}, requests)
+
+	cm.Namespace = "shop"
+	require.Empty(t, deploymentsReferencing(c, configMapIndexField)(context.Background(), cm))
+}
+
+func TestDeploymentReconciler_StampsConfigHash(t *testing.T) {
+	ctx := context.Background()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}, Data: map[string]string{"mode": "a"}}
+	c := newConfigClient(newConfigDeployment(true), cm)
+	recorder := record.NewFakeRecorder(10)
+	r := &DeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: events.NewRecorder(recorder)}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+	hash := func() string {
+		d := &appsv1.Deployment{}
+		require.NoError(t, c.Get(ctx, req.NamespacedName, d))
+		return d.Spec.Template.Annotations[ConfigHashAnnotation]
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	first := hash()
+	require.NotEmpty(t, first)
+	require.Contains(t, <-recorder.Events, "Normal ConfigChanged Tracking configuration configmap/app-config, secret/tls")
+
+	// Reconciling unchanged configuration is a no-op.
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, first, hash())
+	require.Empty(t, recorder.Events)
+
+	cm.Data["mode"] = "b"
+	require.NoError(t, c.Update(ctx, cm))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NotEqual(t, first, hash())
+	require.Contains(t, <-recorder.Events, "Normal ConfigChanged Configuration configmap/app-config, secret/tls changed")
+
+	// Creating the missing Secret changes the hash as well.
+	second := hash()
+	require.NoError(t, c.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"}}))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NotEqual(t, second, hash())
+}
+
+func TestDeploymentReconciler_IgnoresConfigWithoutOptIn(t *testing.T) {
+	ctx := context.Background()
+	c := newConfigClient(newConfigDeployment(false))
+	r := &DeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: events.NewRecorder(record.NewFakeRecorder(10))}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	d := &appsv1.Deployment{}
+	require.NoError(t, c.Get(ctx, req.NamespacedName, d))
+	require.NotContains(t, d.Spec.Template.Annotations, ConfigHashAnnotation)
+}
+
+func TestDeploymentReconciler_ReadsSecretsUncached(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"}, Data: map[string][]byte{"tls.key": []byte("a")}}
+	// Only Secret metadata is cached, so reading a Secret through the client is a bug.
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(newConfigDeployment(true)).
+		WithInterceptorFuncs(interceptor.Funcs{Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.Secret); ok {
+				return errors.New("secret read from the cache")
+			}
+			return c.Get(ctx, key, obj, opts...)
+		}}).
+		Build()
+	apiReader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build()
+	r := &DeploymentReconciler{Client: c, APIReader: apiReader, Scheme: scheme.Scheme, Recorder: events.NewRecorder(&record.FakeRecorder{})}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+	hash := func() string {
+		d := &appsv1.Deployment{}
+		require.NoError(t, c.Get(ctx, req.NamespacedName, d))
+		return d.Spec.Template.Annotations[ConfigHashAnnotation]
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	first := hash()
+
+	secret.Data["tls.key"] = []byte("b")
+	require.NoError(t, apiReader.Update(ctx, secret))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NotEqual(t, first, hash())
+}
+
+func TestDeploymentsReferencing_SecretMetadata(t *testing.T) {
+	c := newConfigClient(newConfigDeployment(true))
+	secret := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"}}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+
+	requests := deploymentsReferencing(c, secretIndexField)(context.Background(), secret)
+
+	require.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}}, requests)
+}
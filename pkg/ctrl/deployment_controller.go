@@ -2,33 +2,190 @@ package ctrl
 
 import (
 	context "context"
+	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
+)
+
+// controllerName is used for the controller and as the source of recorded events.
+const controllerName = "deployment-controller"
+
+// Event reasons recorded on Deployments.
+const (
+	ReasonReplicasClamped      = "ReplicasClamped"
+	ReasonInvalidReplicaBounds = "InvalidReplicaBounds"
+	ReasonConfigChanged        = "ConfigChanged"
+	ReasonImagePolicyViolation = "ImagePolicyViolation"
+	ReasonImagePolicyCompliant = "ImagePolicyCompliant"
+	ReasonImagePolicyEnforced  = "ImagePolicyEnforced"
+	ReasonImagePolicyRestored  = "ImagePolicyRestored"
+	ReasonReconcileFailed      = "ReconcileFailed"
 )
 
 type DeploymentReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder *events.Recorder
+	// APIReader reads Secrets from the API server, since only their metadata
+	// is cached. It defaults to the Client.
+	APIReader client.Reader
+	// ImagePolicy is optional; without it no image checks are made.
+	ImagePolicy *ImagePolicy
+	// RequeueInterval is passed to requeue after every successful reconcile.
+	RequeueInterval time.Duration
+	// Selection limits the Deployments that are reconciled.
+	Selection selection.Config
+	// DryRun sends patches with server-side dry run and only reports the changes.
+	DryRun bool
+}
+
+// DeploymentOptions configures the deployment controller.
+type DeploymentOptions struct {
+	ImagePolicy *ImagePolicy
+	Queue       QueueOptions
+	Selection   selection.Config
+	DryRun      bool
+}
+
+// change is a modification made by one of the reconcile policies, reported as an event once patched.
+type change struct {
+	eventType string
+	reason    string
+	message   string
 }
 
+// Reconcile applies the policies to a Deployment: the image policy, replica
+// bounds and rollouts on configuration changes. All changes are sent in a
+// single patch.
 func (r *DeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log.Info().Msgf("Reconciling Deployment: %s/%s", req.Namespace, req.Name)
-	return ctrl.Result{}, nil
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, req.NamespacedName, deployment); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// Requests mapped from ConfigMaps and Secrets bypass the watch predicates.
+	if !deployment.DeletionTimestamp.IsZero() || !r.Selection.Matches(deployment) {
+		return ctrl.Result{}, nil
+	}
+	original := deployment.DeepCopy()
+
+	changes, blocked := r.applyImagePolicy(deployment)
+	// A Deployment held at zero by the image policy must not be scaled back up to its minimum.
+	if !blocked {
+		if message := r.clampReplicas(deployment); message != "" {
+			changes = append(changes, change{corev1.EventTypeNormal, ReasonReplicasClamped, message})
+		}
+	}
+	message, err := r.stampConfigHash(ctx, deployment)
+	if err != nil {
+		r.Recorder.Warningf(deployment, ReasonReconcileFailed, "Failed to hash the referenced configuration: %v", err)
+		return ctrl.Result{}, err
+	}
+	if message != "" {
+		changes = append(changes, change{corev1.EventTypeNormal, ReasonConfigChanged, message})
+	}
+	if len(changes) == 0 {
+		return requeue(r.RequeueInterval), nil
+	}
+
+	// The optimistic lock makes the patch fail with a conflict if the Deployment
+	// changed since it was read, so a concurrent scale is never overwritten blindly.
+	patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+	var patchOpts []client.PatchOption
+	var patchData []byte
+	if r.DryRun {
+		// Admission and validation still run, so a dry run also catches patches the API server would reject.
+		patchOpts = append(patchOpts, client.DryRunAll)
+		if patchData, err = patch.Data(deployment); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to compute patch of Deployment %s/%s: %w", req.Namespace, req.Name, err)
+		}
+	}
+	if err := r.Patch(ctx, deployment, patch, patchOpts...); err != nil {
+		// Conflicts are retried with backoff against the fresh object and are not worth an event.
+		if !apierrors.IsConflict(err) {
+			r.Recorder.Warningf(original, ReasonReconcileFailed, "Failed to apply changes: %v", err)
+		}
+		return ctrl.Result{}, fmt.Errorf("failed to patch Deployment %s/%s: %w", req.Namespace, req.Name, err)
+	}
+	if r.DryRun {
+		reportDryRun(deployment, patchData, changes)
+	}
+	for _, c := range changes {
+		message := c.message
+		if r.DryRun {
+			message = "Dry run: " + message
+		}
+		r.Recorder.Event(deployment, c.eventType, c.reason, message)
+	}
+	return requeue(r.RequeueInterval), nil
 }
 
-func AddDeploymentController(mgr manager.Manager) error {
+// reportDryRun logs the patch that was not applied and counts its changes.
+func reportDryRun(deployment *appsv1.Deployment, patch []byte, changes []change) {
+	reasons := make([]string, 0, len(changes))
+	for _, c := range changes {
+		reasons = append(reasons, c.reason)
+		dryRunChanges.WithLabelValues(controllerName, c.reason).Inc()
+	}
+	log.Info().
+		Str("deployment", deployment.Namespace+"/"+deployment.Name).
+		Strs("reasons", reasons).
+		RawJSON("patch", patch).
+		Msg("Dry run: Deployment patch not applied")
+}
+
+func AddDeploymentController(mgr manager.Manager, opts DeploymentOptions) error {
+	queue, err := opts.Queue.withDefaults()
+	if err != nil {
+		return fmt.Errorf("invalid queue options: %w", err)
+	}
 	r := &DeploymentReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:          mgr.GetClient(),
+		APIReader:       mgr.GetAPIReader(),
+		Scheme:          mgr.GetScheme(),
+		Recorder:        events.NewRecorder(mgr.GetEventRecorderFor(controllerName)),
+		ImagePolicy:     opts.ImagePolicy,
+		RequeueInterval: queue.RequeueInterval,
+		Selection:       opts.Selection,
+		DryRun:          opts.DryRun,
+	}
+	for _, field := range []string{configMapIndexField, secretIndexField} {
+		if err := mgr.GetFieldIndexer().IndexField(context.Background(), &appsv1.Deployment{}, field, indexConfigRefs(field)); err != nil {
+			return fmt.Errorf("failed to index Deployments by %s: %w", field, err)
+		}
+	}
+	if err := ctrl.NewControllerManagedBy(mgr).
+		// Status-only updates bump neither the generation nor the metadata and are ignored.
+		For(&appsv1.Deployment{}, builder.WithPredicates(
+			opts.Selection.Predicate(),
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}, predicate.LabelChangedPredicate{}),
+		)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(deploymentsReferencing(mgr.GetClient(), configMapIndexField))).
+		// Only the metadata of Secrets is watched, so their data is never cached.
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(deploymentsReferencing(mgr.GetClient(), secretIndexField)), builder.OnlyMetadata).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: queue.MaxConcurrentReconciles,
+			RateLimiter:             queue.rateLimiter(),
+		}).
+		Complete(r); err != nil {
+		return err
 	}
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&appsv1.Deployment{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
-		Complete(r)
+	recordQueueSettings(controllerName, queue)
+	return nil
 }
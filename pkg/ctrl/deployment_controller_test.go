@@ -2,19 +2,28 @@ package ctrl_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/ctrl"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
 	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil" // Import your custom envtest package
 )
 
@@ -39,7 +48,7 @@ func TestDeploymentReconciler(t *testing.T) {
 	require.NoError(t, err)
 
 	// Add the DeploymentReconciler to the manager
-	err = ctrl.AddDeploymentController(mgr)
+	err = ctrl.AddDeploymentController(mgr, ctrl.DeploymentOptions{})
 	require.NoError(t, err)
 
 	// Start the manager in a separate goroutine
@@ -98,7 +107,7 @@ func TestMetricsAndLeaderElection(t *testing.T) {
 	require.NoError(t, err)
 
 	// Add the DeploymentReconciler to the manager
-	err = ctrl.AddDeploymentController(mgr)
+	err = ctrl.AddDeploymentController(mgr, ctrl.DeploymentOptions{})
 	require.NoError(t, err)
 
 	// Start the manager in a separate goroutine with a timeout context
@@ -119,4 +128,181 @@ func TestMetricsAndLeaderElection(t *testing.T) {
 	// This is a basic check - in a real test we might want to query the metrics endpoint
 	// or check for leader election records
 	require.NotNil(t, mgr.GetCache())
-}
\ No newline at end of file
+}
+
+func newReconciler(t *testing.T, objs ...client.Object) (*ctrl.DeploymentReconciler, *record.FakeRecorder) {
+	t.Helper()
+	recorder := record.NewFakeRecorder(10)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()
+	return &ctrl.DeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: events.NewRecorder(recorder)}, recorder
+}
+
+func reconcileDeployment(t *testing.T, r *ctrl.DeploymentReconciler, name string) error {
+	t.Helper()
+	_, err := r.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: name},
+	})
+	return err
+}
+
+func TestDeploymentReconciler_ClampsReplicas(t *testing.T) {
+	tests := []struct {
+		name        string
+		replicas    int32
+		annotations map[string]string
+		want        int32
+		wantEvent   string
+	}{
+		{"below min", 1, map[string]string{ctrl.MinReplicasAnnotation: "3"}, 3,
+			"Normal ReplicasClamped Scaled from 1 to 3 replicas to satisfy tutorial.io/min-replicas=3"},
+		{"above max", 10, map[string]string{ctrl.MaxReplicasAnnotation: "4"}, 4,
+			"Normal ReplicasClamped Scaled from 10 to 4 replicas to satisfy tutorial.io/max-replicas=4"},
+		{"within range", 2, map[string]string{ctrl.MinReplicasAnnotation: "1", ctrl.MaxReplicasAnnotation: "4"}, 2, ""},
+		{"no annotations", 7, nil, 7, ""},
+		{"invalid annotations", 7, map[string]string{ctrl.MinReplicasAnnotation: "5", ctrl.MaxReplicasAnnotation: "2"}, 7,
+			"Warning InvalidReplicaBounds tutorial.io/min-replicas=5 is greater than tutorial.io/max-replicas=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: testutil.NewObjectMeta("web", "default"),
+				Spec:       testutil.NewDeploymentSpec(tt.replicas, map[string]string{"app": "web"}, "nginx"),
+			}
+			deployment.Annotations = tt.annotations
+			r, recorder := newReconciler(t, deployment)
+
+			require.NoError(t, reconcileDeployment(t, r, "web"))
+
+			got := &appsv1.Deployment{}
+			require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(deployment), got))
+			require.Equal(t, tt.want, *got.Spec.Replicas)
+			if tt.wantEvent == "" {
+				require.Empty(t, recorder.Events)
+				return
+			}
+			require.Equal(t, tt.wantEvent, <-recorder.Events)
+		})
+	}
+}
+
+func TestDeploymentReconciler_ConflictIsRetried(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	deployment.Annotations = map[string]string{ctrl.MinReplicasAnnotation: "2"}
+	recorder := record.NewFakeRecorder(10)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				return apierrors.NewConflict(appsv1.Resource("deployments"), obj.GetName(), errors.New("object was modified"))
+			},
+		}).Build()
+	r := &ctrl.DeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: events.NewRecorder(recorder)}
+
+	err := reconcileDeployment(t, r, "web")
+
+	require.True(t, apierrors.IsConflict(err))
+	require.Empty(t, recorder.Events)
+}
+
+func TestDeploymentReconciler_NotFound(t *testing.T) {
+	r, _ := newReconciler(t)
+	require.NoError(t, reconcileDeployment(t, r, "missing"))
+}
+
+func TestDeploymentReconciler_PatchFailureIsRecorded(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	deployment.Annotations = map[string]string{ctrl.MinReplicasAnnotation: "2"}
+	recorder := record.NewFakeRecorder(10)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				return apierrors.NewForbidden(appsv1.Resource("deployments"), obj.GetName(), errors.New("denied by webhook"))
+			},
+		}).Build()
+	r := &ctrl.DeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: events.NewRecorder(recorder)}
+
+	err := reconcileDeployment(t, r, "web")
+
+	require.True(t, apierrors.IsForbidden(err))
+	require.Contains(t, <-recorder.Events, "Warning ReconcileFailed Failed to apply changes:")
+	require.Empty(t, recorder.Events, "changes that were not applied are not reported")
+}
+
+func TestDeploymentReconciler_RequeueInterval(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	r, _ := newReconciler(t, deployment)
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+
+	result, err := r.Reconcile(context.Background(), request)
+	require.NoError(t, err)
+	require.Zero(t, result.RequeueAfter, "periodic requeues are off by default")
+
+	r.RequeueInterval = time.Minute
+	result, err = r.Reconcile(context.Background(), request)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, result.RequeueAfter, time.Minute)
+
+	result, err = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "missing"}})
+	require.NoError(t, err)
+	require.Zero(t, result.RequeueAfter, "deleted Deployments are not requeued")
+}
+
+func TestDeploymentReconciler_SkipsUnselectedDeployments(t *testing.T) {
+	ignored := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("ignored", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "ignored"}, "nginx"),
+	}
+	ignored.Annotations = map[string]string{ctrl.MinReplicasAnnotation: "3", selection.IgnoreAnnotation: "true"}
+	excluded := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("excluded", "kube-system"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "excluded"}, "nginx"),
+	}
+	excluded.Annotations = map[string]string{ctrl.MinReplicasAnnotation: "3"}
+	r, recorder := newReconciler(t, ignored, excluded)
+	r.Selection = selection.Config{ExcludedNamespaces: []string{"kube-system"}}
+
+	for _, d := range []*appsv1.Deployment{ignored, excluded} {
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(d)})
+		require.NoError(t, err)
+		got := &appsv1.Deployment{}
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(d), got))
+		require.Equal(t, int32(1), *got.Spec.Replicas, d.Name)
+	}
+	require.Empty(t, recorder.Events)
+}
+
+func TestDeploymentReconciler_DryRun(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	deployment.Annotations = map[string]string{ctrl.MinReplicasAnnotation: "3"}
+	var dryRun []string
+	recorder := record.NewFakeRecorder(10)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				patchOpts := &client.PatchOptions{}
+				patchOpts.ApplyOptions(opts)
+				dryRun = patchOpts.DryRun
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}).Build()
+	r := &ctrl.DeploymentReconciler{Client: c, Scheme: scheme.Scheme, Recorder: events.NewRecorder(recorder), DryRun: true}
+
+	require.NoError(t, reconcileDeployment(t, r, "web"))
+
+	require.Equal(t, []string{metav1.DryRunAll}, dryRun, "the patch is sent with server-side dry run")
+	got := &appsv1.Deployment{}
+	require.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(deployment), got))
+	require.Equal(t, int32(1), *got.Spec.Replicas)
+	require.Equal(t, "Normal ReplicasClamped Dry run: Scaled from 1 to 3 replicas to satisfy tutorial.io/min-replicas=3", <-recorder.Events)
+}
@@ -0,0 +1,174 @@
+package ctrl
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// ImagePolicyMode selects what happens to Deployments that violate the image policy.
+type ImagePolicyMode string
+
+const (
+	// ImagePolicyAudit annotates violating Deployments and records Warning events.
+	ImagePolicyAudit ImagePolicyMode = "audit"
+	// ImagePolicyEnforce additionally scales violating Deployments to zero until they comply.
+	ImagePolicyEnforce ImagePolicyMode = "enforce"
+)
+
+const (
+	// ImagePolicyViolationsAnnotation lists the current violations of a Deployment.
+	ImagePolicyViolationsAnnotation = "tutorial.io/image-policy-violations"
+	// ImagePolicyScaledFromAnnotation remembers the replicas of a Deployment scaled to zero by the policy.
+	ImagePolicyScaledFromAnnotation = "tutorial.io/image-policy-scaled-from"
+)
+
+// ImagePolicy restricts the container images of Deployments.
+type ImagePolicy struct {
+	// Mode defaults to audit.
+	Mode ImagePolicyMode `json:"mode,omitempty"`
+	// AllowedRegistries are registry hosts or repository prefixes, such as
+	// "registry.k8s.io" or "ghcr.io/acme". Empty allows every registry.
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+	// DisallowLatestTag rejects the latest tag, including images without a tag or digest.
+	DisallowLatestTag bool `json:"disallowLatestTag,omitempty"`
+	// RequireDigest rejects images that are not pinned by digest.
+	RequireDigest bool `json:"requireDigest,omitempty"`
+}
+
+// LoadImagePolicy reads an ImagePolicy from a YAML or JSON file. Unknown fields are rejected.
+func LoadImagePolicy(path string) (*ImagePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image policy: %w", err)
+	}
+	policy := &ImagePolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse image policy %s: %w", path, err)
+	}
+	switch policy.Mode {
+	case "":
+		policy.Mode = ImagePolicyAudit
+	case ImagePolicyAudit, ImagePolicyEnforce:
+	default:
+		return nil, fmt.Errorf("invalid image policy mode %q: must be %q or %q", policy.Mode, ImagePolicyAudit, ImagePolicyEnforce)
+	}
+	return policy, nil
+}
+
+// Violations checks the images of every container and init container of the pod template.
+func (p *ImagePolicy) Violations(spec *corev1.PodSpec) []string {
+	var violations []string
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		violations = append(violations, p.checkImage(c.Name, c.Image)...)
+	}
+	return violations
+}
+
+func (p *ImagePolicy) checkImage(container, image string) []string {
+	var violations []string
+	ref := parseImageReference(image)
+	if len(p.AllowedRegistries) > 0 && !ref.allowedBy(p.AllowedRegistries) {
+		violations = append(violations, fmt.Sprintf("container %q: registry of %s is not allowed", container, image))
+	}
+	if p.DisallowLatestTag && ref.digest == "" && (ref.tag == "" || ref.tag == "latest") {
+		violations = append(violations, fmt.Sprintf("container %q: %s uses the latest tag", container, image))
+	}
+	if p.RequireDigest && ref.digest == "" {
+		violations = append(violations, fmt.Sprintf("container %q: %s is not pinned by digest", container, image))
+	}
+	return violations
+}
+
+// imageReference is a container image split into its parts. The registry is
+// docker.io when the image does not name one.
+type imageReference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+func parseImageReference(image string) imageReference {
+	var ref imageReference
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.digest = name[:i], name[i+1:]
+	}
+	// A colon after the last slash separates the tag; earlier ones belong to a registry port.
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.tag = name[:i], name[i+1:]
+	}
+	ref.registry, ref.repository = "docker.io", name
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.registry, ref.repository = first, rest
+	}
+	return ref
+}
+
+// allowedBy reports whether the image matches one of the registries or repository prefixes.
+func (r imageReference) allowedBy(allowed []string) bool {
+	full := r.registry + "/" + r.repository
+	for _, prefix := range allowed {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if r.registry == prefix || strings.HasPrefix(full, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyImagePolicy records violations in an annotation and, in enforce mode,
+// scales a violating Deployment to zero. Replicas are restored once it complies.
+// blocked reports that the Deployment must stay scaled down.
+func (r *DeploymentReconciler) applyImagePolicy(deployment *appsv1.Deployment) (changes []change, blocked bool) {
+	var violations []string
+	if r.ImagePolicy != nil {
+		violations = r.ImagePolicy.Violations(&deployment.Spec.Template.Spec)
+	}
+	summary := strings.Join(violations, "; ")
+	if summary != deployment.Annotations[ImagePolicyViolationsAnnotation] {
+		if summary == "" {
+			delete(deployment.Annotations, ImagePolicyViolationsAnnotation)
+			changes = append(changes, change{corev1.EventTypeNormal, ReasonImagePolicyCompliant, "Images comply with the image policy"})
+		} else {
+			setAnnotation(deployment, ImagePolicyViolationsAnnotation, summary)
+			changes = append(changes, change{corev1.EventTypeWarning, ReasonImagePolicyViolation, summary})
+		}
+	}
+
+	blocked = len(violations) > 0 && r.ImagePolicy.Mode == ImagePolicyEnforce
+	scaledFrom, scaled := deployment.Annotations[ImagePolicyScaledFromAnnotation]
+	current := desiredReplicas(deployment)
+	switch {
+	case blocked && current > 0:
+		setAnnotation(deployment, ImagePolicyScaledFromAnnotation, strconv.Itoa(int(current)))
+		deployment.Spec.Replicas = new(int32)
+		changes = append(changes, change{corev1.EventTypeWarning, ReasonImagePolicyEnforced,
+			fmt.Sprintf("Scaled from %d to 0 replicas until the images comply with the image policy", current)})
+	case !blocked && scaled:
+		delete(deployment.Annotations, ImagePolicyScaledFromAnnotation)
+		replicas, err := strconv.ParseInt(scaledFrom, 10, 32)
+		if err != nil || current != 0 {
+			// Someone scaled the Deployment in the meantime; keep their choice.
+			break
+		}
+		restored := int32(replicas)
+		deployment.Spec.Replicas = &restored
+		changes = append(changes, change{corev1.EventTypeNormal, ReasonImagePolicyRestored,
+			fmt.Sprintf("Restored %d replicas scaled down by the image policy", restored)})
+	}
+	return changes, blocked
+}
+
+func setAnnotation(deployment *appsv1.Deployment, key, value string) {
+	if deployment.Annotations == nil {
+		deployment.Annotations = map[string]string{}
+	}
+	deployment.Annotations[key] = value
+}
@@ -0,0 +1,176 @@
+package ctrl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
+)
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadImagePolicy(t *testing.T) {
+	policy, err := LoadImagePolicy(writePolicy(t, `
+mode: enforce
+allowedRegistries: [ghcr.io/acme, registry.k8s.io]
+disallowLatestTag: true
+requireDigest: true
+`))
+	require.NoError(t, err)
+	require.Equal(t, &ImagePolicy{
+		Mode:              ImagePolicyEnforce,
+		AllowedRegistries: []string{"ghcr.io/acme", "registry.k8s.io"},
+		DisallowLatestTag: true,
+		RequireDigest:     true,
+	}, policy)
+
+	policy, err = LoadImagePolicy(writePolicy(t, "disallowLatestTag: true\n"))
+	require.NoError(t, err)
+	require.Equal(t, ImagePolicyAudit, policy.Mode)
+
+	_, err = LoadImagePolicy(writePolicy(t, "mode: block\n"))
+	require.ErrorContains(t, err, `invalid image policy mode "block"`)
+
+	_, err = LoadImagePolicy(writePolicy(t, "allowedRegistry: [ghcr.io]\n"))
+	require.ErrorContains(t, err, "unknown field")
+
+	_, err = LoadImagePolicy(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+}
+
+func TestParseImageReference(t *testing.T) {
+	tests := map[string]imageReference{
+		"nginx":                        {registry: "docker.io", repository: "nginx"},
+		"library/nginx:1.27":           {registry: "docker.io", repository: "library/nginx", tag: "1.27"},
+		"localhost:5000/app":           {registry: "localhost:5000", repository: "app"},
+		"localhost/app:v1":             {registry: "localhost", repository: "app", tag: "v1"},
+		"ghcr.io/acme/api@sha256:abcd": {registry: "ghcr.io", repository: "acme/api", digest: "sha256:abcd"},
+		"ghcr.io/acme/api:1@sha256:ab": {registry: "ghcr.io", repository: "acme/api", tag: "1", digest: "sha256:ab"},
+	}
+	for image, want := range tests {
+		require.Equal(t, want, parseImageReference(image), image)
+	}
+}
+
+func TestImagePolicy_Violations(t *testing.T) {
+	policy := &ImagePolicy{
+		AllowedRegistries: []string{"ghcr.io/acme", "registry.k8s.io/"},
+		DisallowLatestTag: true,
+	}
+	tests := []struct {
+		image string
+		want  []string
+	}{
+		{"ghcr.io/acme/api:1.2.3", nil},
+		{"registry.k8s.io/pause:3.10", nil},
+		{"ghcr.io/acme-evil/api:1", []string{"container \"app\": registry of ghcr.io/acme-evil/api:1 is not allowed"}},
+		{"nginx", []string{
+			"container \"app\": registry of nginx is not allowed",
+			"container \"app\": nginx uses the latest tag",
+		}},
+		{"ghcr.io/acme/api:latest", []string{"container \"app\": ghcr.io/acme/api:latest uses the latest tag"}},
+		{"ghcr.io/acme/api@sha256:abcd", nil},
+	}
+	for _, tt := range tests {
+		spec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: tt.image}}}
+		require.Equal(t, tt.want, policy.Violations(spec), tt.image)
+	}
+
+	digest := &ImagePolicy{RequireDigest: true}
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Image: "busybox:1.36"}},
+		Containers:     []corev1.Container{{Name: "app", Image: "nginx@sha256:abcd"}},
+	}
+	require.Equal(t, []string{"container \"init\": busybox:1.36 is not pinned by digest"}, digest.Violations(spec))
+}
+
+func TestDeploymentReconciler_ImagePolicy(t *testing.T) {
+	ctx := context.Background()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(3, map[string]string{"app": "web"}, "nginx:latest"),
+	}
+	deployment.Annotations = map[string]string{MinReplicasAnnotation: "2"}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &DeploymentReconciler{
+		Client:      c,
+		Scheme:      scheme.Scheme,
+		Recorder:    events.NewRecorder(recorder),
+		ImagePolicy: &ImagePolicy{Mode: ImagePolicyEnforce, DisallowLatestTag: true},
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+	get := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		require.NoError(t, c.Get(ctx, req.NamespacedName, d))
+		return d
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	got := get()
+	require.Equal(t, int32(0), *got.Spec.Replicas, "enforce mode overrides the minimum replicas")
+	require.Equal(t, "3", got.Annotations[ImagePolicyScaledFromAnnotation])
+	require.Equal(t, "container \"container\": nginx:latest uses the latest tag", got.Annotations[ImagePolicyViolationsAnnotation])
+	require.Equal(t, "Warning ImagePolicyViolation container \"container\": nginx:latest uses the latest tag", <-recorder.Events)
+	require.Equal(t, "Warning ImagePolicyEnforced Scaled from 3 to 0 replicas until the images comply with the image policy", <-recorder.Events)
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.Empty(t, recorder.Events, "unchanged violations are not reported again")
+
+	got.Spec.Template.Spec.Containers[0].Image = "nginx:1.27"
+	require.NoError(t, c.Update(ctx, got))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	got = get()
+	require.Equal(t, int32(3), *got.Spec.Replicas)
+	require.NotContains(t, got.Annotations, ImagePolicyScaledFromAnnotation)
+	require.NotContains(t, got.Annotations, ImagePolicyViolationsAnnotation)
+	require.Equal(t, "Normal ImagePolicyCompliant Images comply with the image policy", <-recorder.Events)
+	require.Equal(t, "Normal ImagePolicyRestored Restored 3 replicas scaled down by the image policy", <-recorder.Events)
+}
+
+func TestDeploymentReconciler_ImagePolicyAudit(t *testing.T) {
+	ctx := context.Background()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(3, map[string]string{"app": "web"}, "nginx"),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &DeploymentReconciler{
+		Client:      c,
+		Scheme:      scheme.Scheme,
+		Recorder:    events.NewRecorder(recorder),
+		ImagePolicy: &ImagePolicy{Mode: ImagePolicyAudit, RequireDigest: true},
+	}
+
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}})
+	require.NoError(t, err)
+
+	got := &appsv1.Deployment{}
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, got))
+	require.Equal(t, ptr.To[int32](3), got.Spec.Replicas)
+	require.Equal(t, "container \"container\": nginx is not pinned by digest", got.Annotations[ImagePolicyViolationsAnnotation])
+	require.Equal(t, "Warning ImagePolicyViolation container \"container\": nginx is not pinned by digest", <-recorder.Events)
+	require.Empty(t, recorder.Events)
+}
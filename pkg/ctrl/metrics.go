@@ -0,0 +1,38 @@
+package ctrl
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// queueSettings exposes the queue options next to controller-runtime's
+// reconcile and workqueue metrics, so that tuning changes can be correlated with them.
+var queueSettings = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tutorial_controller_queue_setting",
+	Help: "Work queue settings of the controller: max_concurrent_reconciles, base_backoff_seconds, max_backoff_seconds, qps, burst and requeue_interval_seconds.",
+}, []string{"controller", "setting"})
+
+// dryRunChanges counts the changes a controller in dry-run mode did not apply.
+// A change is counted again on every reconcile until the mode is turned off.
+var dryRunChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "tutorial_controller_dry_run_changes_total",
+	Help: "Changes computed but not applied in dry-run mode, by event reason.",
+}, []string{"controller", "reason"})
+
+func init() {
+	metrics.Registry.MustRegister(queueSettings, dryRunChanges)
+}
+
+func recordQueueSettings(controller string, o QueueOptions) {
+	settings := map[string]float64{
+		"max_concurrent_reconciles": float64(o.MaxConcurrentReconciles),
+		"base_backoff_seconds":      o.BaseBackoff.Seconds(),
+		"max_backoff_seconds":       o.MaxBackoff.Seconds(),
+		"qps":                       o.QPS,
+		"burst":                     float64(o.Burst),
+		"requeue_interval_seconds":  o.RequeueInterval.Seconds(),
+	}
+	for setting, value := range settings {
+		queueSettings.WithLabelValues(controller, setting).Set(value)
+	}
+}
@@ -0,0 +1,44 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordQueueSettings(t *testing.T) {
+	recordQueueSettings("test-controller", QueueOptions{
+		MaxConcurrentReconciles: 4,
+		BaseBackoff:             500 * time.Millisecond,
+		MaxBackoff:              time.Minute,
+		QPS:                     20,
+		Burst:                   200,
+		RequeueInterval:         10 * time.Minute,
+	})
+
+	for setting, want := range map[string]float64{
+		"max_concurrent_reconciles": 4,
+		"base_backoff_seconds":      0.5,
+		"max_backoff_seconds":       60,
+		"qps":                       20,
+		"burst":                     200,
+		"requeue_interval_seconds":  600,
+	} {
+		require.Equal(t, want, testutil.ToFloat64(queueSettings.WithLabelValues("test-controller", setting)), setting)
+	}
+}
+
+func TestReportDryRun(t *testing.T) {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	counter := dryRunChanges.WithLabelValues(controllerName, ReasonReplicasClamped)
+	before := testutil.ToFloat64(counter)
+
+	reportDryRun(deployment, []byte(`{"spec":{"replicas":3}}`), []change{{corev1.EventTypeNormal, ReasonReplicasClamped, "Scaled"}})
+
+	require.Equal(t, before+1, testutil.ToFloat64(counter))
+}
@@ -0,0 +1,89 @@
+package ctrl
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Defaults of the work queue settings, matching workqueue.DefaultTypedControllerRateLimiter.
+const (
+	DefaultMaxConcurrentReconciles = 1
+	DefaultBaseBackoff             = 5 * time.Millisecond
+	DefaultMaxBackoff              = 1000 * time.Second
+	DefaultQPS                     = 10
+	DefaultBurst                   = 100
+)
+
+// requeueJitter spreads periodic requeues so that Deployments created together
+// are not all reconciled at the same moment again.
+const requeueJitter = 0.1
+
+// QueueOptions tunes how fast the controller works through its queue.
+type QueueOptions struct {
+	// MaxConcurrentReconciles defaults to DefaultMaxConcurrentReconciles.
+	MaxConcurrentReconciles int
+	// BaseBackoff and MaxBackoff bound the per-Deployment exponential backoff
+	// after failed reconciles. They default to DefaultBaseBackoff and DefaultMaxBackoff.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// QPS and Burst configure the token bucket shared by all Deployments. They
+	// default to DefaultQPS and DefaultBurst.
+	QPS   float64
+	Burst int
+	// RequeueInterval reconciles every Deployment again after a successful
+	// reconcile, with up to 10% jitter. Zero only reconciles on changes.
+	RequeueInterval time.Duration
+}
+
+// withDefaults fills in the unset options and validates the rest.
+func (o QueueOptions) withDefaults() (QueueOptions, error) {
+	if o.MaxConcurrentReconciles == 0 {
+		o.MaxConcurrentReconciles = DefaultMaxConcurrentReconciles
+	}
+	if o.BaseBackoff == 0 {
+		o.BaseBackoff = DefaultBaseBackoff
+	}
+	if o.MaxBackoff == 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+	if o.QPS == 0 {
+		o.QPS = DefaultQPS
+	}
+	if o.Burst == 0 {
+		o.Burst = DefaultBurst
+	}
+	switch {
+	case o.MaxConcurrentReconciles < 0:
+		return o, fmt.Errorf("max concurrent reconciles must be positive, got %d", o.MaxConcurrentReconciles)
+	case o.BaseBackoff < 0 || o.MaxBackoff < o.BaseBackoff:
+		return o, fmt.Errorf("backoff must satisfy 0 < base <= max, got base %s and max %s", o.BaseBackoff, o.MaxBackoff)
+	case o.QPS < 0 || o.Burst < 0:
+		return o, fmt.Errorf("QPS and burst must be positive, got %g and %d", o.QPS, o.Burst)
+	case o.RequeueInterval < 0:
+		return o, fmt.Errorf("requeue interval must not be negative, got %s", o.RequeueInterval)
+	}
+	return o, nil
+}
+
+// rateLimiter delays a Deployment by the larger of its own failure backoff and
+// the wait for a token from the shared bucket.
+func (o QueueOptions) rateLimiter() workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](o.BaseBackoff, o.MaxBackoff),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(o.QPS), o.Burst)},
+	)
+}
+
+// requeue returns the result of a successful reconcile.
+func requeue(interval time.Duration) ctrl.Result {
+	if interval <= 0 {
+		return ctrl.Result{}
+	}
+	return ctrl.Result{RequeueAfter: wait.Jitter(interval, requeueJitter)}
+}
@@ -0,0 +1,66 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestQueueOptions_WithDefaults(t *testing.T) {
+	got, err := QueueOptions{}.withDefaults()
+	require.NoError(t, err)
+	require.Equal(t, QueueOptions{
+		MaxConcurrentReconciles: DefaultMaxConcurrentReconciles,
+		BaseBackoff:             DefaultBaseBackoff,
+		MaxBackoff:              DefaultMaxBackoff,
+		QPS:                     DefaultQPS,
+		Burst:                   DefaultBurst,
+	}, got)
+
+	custom := QueueOptions{MaxConcurrentReconciles: 8, BaseBackoff: time.Second, MaxBackoff: time.Minute, QPS: 50, Burst: 500, RequeueInterval: time.Hour}
+	got, err = custom.withDefaults()
+	require.NoError(t, err)
+	require.Equal(t, custom, got)
+
+	for name, opts := range map[string]QueueOptions{
+		"negative workers":  {MaxConcurrentReconciles: -1},
+		"max below base":    {BaseBackoff: time.Minute, MaxBackoff: time.Second},
+		"negative qps":      {QPS: -1},
+		"negative interval": {RequeueInterval: -time.Second},
+	} {
+		_, err := opts.withDefaults()
+		require.Error(t, err, name)
+	}
+}
+
+func TestQueueOptions_RateLimiter(t *testing.T) {
+	opts := QueueOptions{BaseBackoff: time.Second, MaxBackoff: 4 * time.Second, QPS: 1000, Burst: 1000}
+	limiter := opts.rateLimiter()
+	item := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}}
+
+	var delays []time.Duration
+	for range 4 {
+		delays = append(delays, limiter.When(item))
+	}
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}, delays)
+
+	limiter.Forget(item)
+	require.Equal(t, time.Second, limiter.When(item), "backoff restarts after a successful reconcile")
+
+	// Without failures, the shared bucket delays the items once the burst is spent.
+	bucket := QueueOptions{BaseBackoff: time.Nanosecond, MaxBackoff: time.Nanosecond, QPS: 1, Burst: 1}.rateLimiter()
+	require.LessOrEqual(t, bucket.When(reconcile.Request{NamespacedName: types.NamespacedName{Name: "a"}}), time.Nanosecond)
+	require.Greater(t, bucket.When(reconcile.Request{NamespacedName: types.NamespacedName{Name: "b"}}), 500*time.Millisecond)
+}
+
+func TestRequeue(t *testing.T) {
+	require.Zero(t, requeue(0))
+	for range 10 {
+		after := requeue(time.Minute).RequeueAfter
+		require.GreaterOrEqual(t, after, time.Minute)
+		require.LessOrEqual(t, after, time.Minute+6*time.Second)
+	}
+}
@@ -0,0 +1,87 @@
+package ctrl
+
+import (
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Annotations that bound spec.replicas of a Deployment. Either may be omitted.
+const (
+	MinReplicasAnnotation = "tutorial.io/min-replicas"
+	MaxReplicasAnnotation = "tutorial.io/max-replicas"
+)
+
+// replicaBounds is the inclusive replica range requested by a Deployment's annotations.
+type replicaBounds struct {
+	min, max *int32
+}
+
+// parseReplicaBounds reads the bound annotations. Values must be non-negative
+// integers and min must not exceed max.
+func parseReplicaBounds(annotations map[string]string) (replicaBounds, error) {
+	var b replicaBounds
+	var err error
+	if b.min, err = parseReplicaAnnotation(annotations, MinReplicasAnnotation); err != nil {
+		return b, err
+	}
+	if b.max, err = parseReplicaAnnotation(annotations, MaxReplicasAnnotation); err != nil {
+		return b, err
+	}
+	if b.min != nil && b.max != nil && *b.min > *b.max {
+		return b, fmt.Errorf("%s=%d is greater than %s=%d", MinReplicasAnnotation, *b.min, MaxReplicasAnnotation, *b.max)
+	}
+	return b, nil
+}
+
+func parseReplicaAnnotation(annotations map[string]string, key string) (*int32, error) {
+	value, ok := annotations[key]
+	if !ok {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%s=%q is not a non-negative integer", key, value)
+	}
+	replicas := int32(n)
+	return &replicas, nil
+}
+
+// clamp returns replicas moved into the range and the annotation that caused a change, if any.
+func (b replicaBounds) clamp(replicas int32) (int32, string) {
+	switch {
+	case b.min != nil && replicas < *b.min:
+		return *b.min, MinReplicasAnnotation
+	case b.max != nil && replicas > *b.max:
+		return *b.max, MaxReplicasAnnotation
+	}
+	return replicas, ""
+}
+
+// clampReplicas moves spec.replicas into the annotated range and describes the
+// change. Invalid annotations are reported with a Warning event and ignored;
+// retrying does not help until they are fixed, which triggers a new reconcile.
+func (r *DeploymentReconciler) clampReplicas(deployment *appsv1.Deployment) string {
+	bounds, err := parseReplicaBounds(deployment.Annotations)
+	if err != nil {
+		r.Recorder.Event(deployment, corev1.EventTypeWarning, ReasonInvalidReplicaBounds, err.Error())
+		return ""
+	}
+	current := desiredReplicas(deployment)
+	replicas, annotation := bounds.clamp(current)
+	if replicas == current {
+		return ""
+	}
+	deployment.Spec.Replicas = &replicas
+	return fmt.Sprintf("Scaled from %d to %d replicas to satisfy %s=%s", current, replicas, annotation, deployment.Annotations[annotation])
+}
+
+// desiredReplicas returns spec.replicas, which the API server defaults to 1 when unset.
+func desiredReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
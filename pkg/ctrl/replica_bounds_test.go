@@ -0,0 +1,57 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestParseReplicaBounds(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        replicaBounds
+		wantErr     string
+	}{
+		{"none", nil, replicaBounds{}, ""},
+		{"min only", map[string]string{MinReplicasAnnotation: "2"}, replicaBounds{min: ptr.To[int32](2)}, ""},
+		{"both", map[string]string{MinReplicasAnnotation: "0", MaxReplicasAnnotation: "5"}, replicaBounds{min: ptr.To[int32](0), max: ptr.To[int32](5)}, ""},
+		{"negative", map[string]string{MaxReplicasAnnotation: "-1"}, replicaBounds{}, "not a non-negative integer"},
+		{"not a number", map[string]string{MinReplicasAnnotation: "two"}, replicaBounds{}, "not a non-negative integer"},
+		{"min above max", map[string]string{MinReplicasAnnotation: "4", MaxReplicasAnnotation: "3"}, replicaBounds{}, "greater than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReplicaBounds(tt.annotations)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReplicaBounds_Clamp(t *testing.T) {
+	b := replicaBounds{min: ptr.To[int32](2), max: ptr.To[int32](4)}
+
+	replicas, annotation := b.clamp(1)
+	require.Equal(t, int32(2), replicas)
+	require.Equal(t, MinReplicasAnnotation, annotation)
+
+	replicas, annotation = b.clamp(3)
+	require.Equal(t, int32(3), replicas)
+	require.Empty(t, annotation)
+
+	replicas, annotation = b.clamp(9)
+	require.Equal(t, int32(4), replicas)
+	require.Equal(t, MaxReplicasAnnotation, annotation)
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	require.Equal(t, int32(1), desiredReplicas(&appsv1.Deployment{}))
+	require.Equal(t, int32(0), desiredReplicas(&appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](0)}}))
+}
@@ -0,0 +1,67 @@
+// Package events records Kubernetes Events together with a matching log line.
+package events
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Recorder records Events on the objects a controller acts on, so that its
+// decisions and failures show up in kubectl describe, and logs them. A nil
+// *Recorder, or one without an EventRecorder, only logs.
+type Recorder struct {
+	recorder record.EventRecorder
+}
+
+// NewRecorder wraps an EventRecorder, usually from manager.GetEventRecorderFor.
+func NewRecorder(recorder record.EventRecorder) *Recorder {
+	return &Recorder{recorder: recorder}
+}
+
+// Normalf records a Normal event and logs it at info level.
+func (r *Recorder) Normalf(obj runtime.Object, reason, messageFmt string, args ...any) {
+	r.Event(obj, corev1.EventTypeNormal, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// Warningf records a Warning event and logs it at warn level.
+func (r *Recorder) Warningf(obj runtime.Object, reason, messageFmt string, args ...any) {
+	r.Event(obj, corev1.EventTypeWarning, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// Event records an event of the given type.
+func (r *Recorder) Event(obj runtime.Object, eventType, reason, message string) {
+	level := zerolog.InfoLevel
+	if eventType == corev1.EventTypeWarning {
+		level = zerolog.WarnLevel
+	}
+	log.WithLevel(level).Str("reason", reason).Msgf("%s %s: %s", kindOf(obj), objectKey(obj), message)
+	if r != nil && r.recorder != nil {
+		r.recorder.Event(obj, eventType, reason, message)
+	}
+}
+
+// kindOf returns the kind of obj. Typed objects read through a client usually have no TypeMeta.
+func kindOf(obj runtime.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+}
+
+func objectKey(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "<unknown>"
+	}
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
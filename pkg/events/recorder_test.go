@@ -0,0 +1,44 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRecorder(t *testing.T) {
+	fake := record.NewFakeRecorder(10)
+	r := NewRecorder(fake)
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	r.Normalf(d, "Scaled", "Scaled to %d replicas", 3)
+	r.Warningf(d, "Failed", "Failed to patch: %v", "conflict")
+	r.Event(d, corev1.EventTypeNormal, "Synced", "Synced")
+
+	require.Equal(t, "Normal Scaled Scaled to 3 replicas", <-fake.Events)
+	require.Equal(t, "Warning Failed Failed to patch: conflict", <-fake.Events)
+	require.Equal(t, "Normal Synced Synced", <-fake.Events)
+}
+
+func TestRecorder_WithoutEventRecorder(t *testing.T) {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	require.NotPanics(t, func() {
+		var r *Recorder
+		r.Warningf(d, "Failed", "only logged")
+		NewRecorder(nil).Normalf(d, "Scaled", "only logged")
+	})
+}
+
+func TestKindAndKey(t *testing.T) {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	require.Equal(t, "Deployment", kindOf(d))
+	require.Equal(t, "default/web", objectKey(d))
+
+	ns := &corev1.Namespace{TypeMeta: metav1.TypeMeta{Kind: "Namespace"}, ObjectMeta: metav1.ObjectMeta{Name: "payments"}}
+	require.Equal(t, "Namespace", kindOf(ns))
+	require.Equal(t, "payments", objectKey(ns))
+}
@@ -0,0 +1,165 @@
+package informer
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EventType is the kind of change reported for a Deployment.
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+)
+
+// DeploymentEvent is a single change observed by the informer. ID is
+// assigned by the Broadcaster and identifies the event for resuming;
+// ResourceVersion is not unique, as a delete tombstone repeats the last known one.
+type DeploymentEvent struct {
+	ID              string            `json:"id,omitempty"`
+	Type            EventType         `json:"type"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Deployment      DeploymentSummary `json:"deployment"`
+}
+
+// deploymentFrom returns the Deployment of an informer callback object, unwrapping delete tombstones.
+func deploymentFrom(obj any) (*appsv1.Deployment, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	d, ok := obj.(*appsv1.Deployment)
+	return d, ok
+}
+
+// newDeploymentEvent builds an event from an informer callback object.
+func newDeploymentEvent(t EventType, obj any) (DeploymentEvent, bool) {
+	d, ok := deploymentFrom(obj)
+	if !ok {
+		return DeploymentEvent{}, false
+	}
+	return DeploymentEvent{
+		Type:            t,
+		ResourceVersion: d.ResourceVersion,
+		Deployment:      NewDeploymentSummary(d),
+	}, true
+}
+
+// subscriberBuffer is the number of events a subscriber may lag behind before it is dropped.
+const subscriberBuffer = 256
+
+// Broadcaster fans informer events out to subscribers and keeps a bounded
+// history so that reconnecting subscribers can resume from an event ID.
+type Broadcaster struct {
+	mu sync.Mutex
+	// epoch prefixes the event IDs so that IDs from a previous process never match.
+	epoch       string
+	seq         uint64
+	history     []DeploymentEvent
+	historySize int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// Subscription receives events published after it was created.
+type Subscription struct {
+	// Events is closed when the subscription is closed, the broadcaster
+	// shuts down, or the subscriber falls too far behind.
+	Events <-chan DeploymentEvent
+	// Replay holds the events missed since the requested event ID.
+	Replay []DeploymentEvent
+	// Resumed is false when an event ID was requested but is no longer
+	// in the history; the subscriber should then rebuild its state from a snapshot.
+	Resumed bool
+
+	events      chan DeploymentEvent
+	broadcaster *Broadcaster
+}
+
+// NewBroadcaster creates a Broadcaster that remembers the last historySize events.
+func NewBroadcaster(historySize int) *Broadcaster {
+	return &Broadcaster{
+		epoch:       strconv.FormatUint(rand.Uint64(), 36),
+		historySize: historySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish assigns the event the next ID, records it and delivers it to every subscriber.
+func (b *Broadcaster) Publish(ev DeploymentEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.seq++
+	ev.ID = b.epoch + "-" + strconv.FormatUint(b.seq, 10)
+	b.history = append(b.history, ev)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+	for s := range b.subscribers {
+		select {
+		case s.events <- ev:
+		default:
+			// Slow consumers are disconnected and expected to resume from their last event ID.
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber. When lastEventID is not empty the
+// events recorded after it are returned in Replay.
+func (b *Broadcaster) Subscribe(lastEventID string) *Subscription {
+	events := make(chan DeploymentEvent, subscriberBuffer)
+	s := &Subscription{Events: events, events: events, broadcaster: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lastEventID != "" {
+		for i := len(b.history) - 1; i >= 0; i-- {
+			if b.history[i].ID == lastEventID {
+				s.Replay = append([]DeploymentEvent(nil), b.history[i+1:]...)
+				s.Resumed = true
+				break
+			}
+		}
+	}
+	if b.closed {
+		close(events)
+		return s
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Close stops delivering events to the subscription.
+func (s *Subscription) Close() {
+	s.broadcaster.mu.Lock()
+	defer s.broadcaster.mu.Unlock()
+	s.broadcaster.remove(s)
+}
+
+// Shutdown closes every subscription and rejects new ones.
+func (b *Broadcaster) Shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
+
+// remove must be called with b.mu held.
+func (b *Broadcaster) remove(s *Subscription) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+	delete(b.subscribers, s)
+	close(s.events)
+}
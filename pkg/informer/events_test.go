@@ -0,0 +1,134 @@
+package informer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func testEvent(rv string) DeploymentEvent {
+	return DeploymentEvent{Type: EventModified, ResourceVersion: rv}
+}
+
+func TestBroadcaster_PublishSubscribe(t *testing.T) {
+	b := NewBroadcaster(10)
+	sub := b.Subscribe("")
+	defer sub.Close()
+
+	b.Publish(testEvent("1"))
+	b.Publish(testEvent("2"))
+
+	require.Equal(t, "1", (<-sub.Events).ResourceVersion)
+	require.Equal(t, "2", (<-sub.Events).ResourceVersion)
+	require.False(t, sub.Resumed)
+	require.Empty(t, sub.Replay)
+}
+
+func TestBroadcaster_Resume(t *testing.T) {
+	b := NewBroadcaster(3)
+	var ids []string
+	for _, rv := range []string{"1", "2", "3", "4"} {
+		b.Publish(testEvent(rv))
+		ids = append(ids, b.history[len(b.history)-1].ID)
+	}
+
+	sub := b.Subscribe(ids[1])
+	defer sub.Close()
+	require.True(t, sub.Resumed)
+	require.Len(t, sub.Replay, 2)
+	require.Equal(t, "3", sub.Replay[0].ResourceVersion)
+	require.Equal(t, "4", sub.Replay[1].ResourceVersion)
+
+	latest := b.Subscribe(ids[3])
+	defer latest.Close()
+	require.True(t, latest.Resumed)
+	require.Empty(t, latest.Replay)
+
+	// The first event has been evicted from the history.
+	expired := b.Subscribe(ids[0])
+	defer expired.Close()
+	require.False(t, expired.Resumed)
+
+	// IDs of another broadcaster, e.g. before a restart, are never resumed.
+	other := NewBroadcaster(3).Subscribe(ids[3])
+	require.False(t, other.Resumed)
+}
+
+func TestBroadcaster_ResumeAcrossTombstoneDelete(t *testing.T) {
+	b := NewBroadcaster(10)
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "7"}}
+	modified, _ := newDeploymentEvent(EventModified, dep)
+	// A delete missed by the watch is delivered as a tombstone carrying the last known resourceVersion.
+	deleted, _ := newDeploymentEvent(EventDeleted, cache.DeletedFinalStateUnknown{Key: "default/web", Obj: dep})
+	require.Equal(t, modified.ResourceVersion, deleted.ResourceVersion)
+
+	live := b.Subscribe("")
+	defer live.Close()
+	b.Publish(modified)
+	received := <-live.Events
+
+	b.Publish(deleted)
+	sub := b.Subscribe(received.ID)
+	defer sub.Close()
+	require.True(t, sub.Resumed)
+	require.Len(t, sub.Replay, 1)
+	require.Equal(t, EventDeleted, sub.Replay[0].Type)
+	require.NotEqual(t, received.ID, sub.Replay[0].ID)
+}
+
+func TestBroadcaster_DropsSlowSubscriber(t *testing.T) {
+	b := NewBroadcaster(1)
+	sub := b.Subscribe("")
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(testEvent("rv"))
+	}
+
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	require.Equal(t, subscriberBuffer, received)
+	sub.Close() // closing a dropped subscription is a no-op
+}
+
+func TestBroadcaster_Shutdown(t *testing.T) {
+	b := NewBroadcaster(10)
+	sub := b.Subscribe("")
+
+	b.Shutdown()
+	_, ok := <-sub.Events
+	require.False(t, ok)
+
+	b.Publish(testEvent("1"))
+	late := b.Subscribe("")
+	_, ok = <-late.Events
+	require.False(t, ok)
+}
+
+func TestNewDeploymentEvent(t *testing.T) {
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "42"}}
+
+	ev, ok := newDeploymentEvent(EventAdded, dep)
+	require.True(t, ok)
+	require.Equal(t, EventAdded, ev.Type)
+	require.Equal(t, "42", ev.ResourceVersion)
+	require.Equal(t, "web", ev.Deployment.Name)
+
+	ev, ok = newDeploymentEvent(EventDeleted, cache.DeletedFinalStateUnknown{Key: "default/web", Obj: dep})
+	require.True(t, ok)
+	require.Equal(t, "web", ev.Deployment.Name)
+
+	_, ok = newDeploymentEvent(EventAdded, "not-a-deployment")
+	require.False(t, ok)
+}
+
+func TestIsResync(t *testing.T) {
+	a := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}
+	b := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}}
+	require.True(t, isResync(a, a.DeepCopy()))
+	require.False(t, isResync(a, b))
+}
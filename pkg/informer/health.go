@@ -0,0 +1,64 @@
+package informer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// activity records when the informers last showed progress: a delivered
+// change, or a new resourceVersion from a relist or watch bookmark.
+type activity struct {
+	mu        sync.Mutex
+	last      time.Time
+	versions  map[cache.SharedIndexInformer]string
+	lastError error
+}
+
+func (a *activity) touch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.last = time.Now()
+}
+
+func (a *activity) watchFailed(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastError = err
+}
+
+// watchErrorHandler records watch failures before handing them to the client-go default handler.
+func (c *DeploymentCache) watchErrorHandler(ctx context.Context, r *cache.Reflector, err error) {
+	c.activity.watchFailed(err)
+	cache.DefaultWatchErrorHandler(ctx, r, err)
+}
+
+// CheckLiveness returns an error when none of the informers has made
+// progress for longer than maxIdle. Progress is an add, update or delete
+// delivered to the handlers, or a change of the last synced resourceVersion,
+// so an empty namespace does not look stalled as long as its watch keeps
+// receiving bookmarks or relisting. Periodic resyncs are not progress: they
+// replay the cache even when the watch has stopped.
+func (c *DeploymentCache) CheckLiveness(maxIdle time.Duration) error {
+	c.activity.mu.Lock()
+	defer c.activity.mu.Unlock()
+	for _, inf := range c.informers() {
+		rv := inf.informer.LastSyncResourceVersion()
+		if rv != c.activity.versions[inf.informer] {
+			c.activity.versions[inf.informer] = rv
+			c.activity.last = time.Now()
+			c.activity.lastError = nil
+		}
+	}
+	idle := time.Since(c.activity.last)
+	if idle <= maxIdle {
+		return nil
+	}
+	if c.activity.lastError != nil {
+		return fmt.Errorf("deployment informer made no progress for %s, last watch error: %w", idle.Round(time.Second), c.activity.lastError)
+	}
+	return fmt.Errorf("deployment informer made no progress for %s", idle.Round(time.Second))
+}
@@ -0,0 +1,66 @@
+package informer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestDeploymentCache_CheckLiveness(t *testing.T) {
+	t.Parallel()
+	clientset := fake.NewClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"default"}})
+	c.Start(ctx)
+	require.Eventually(t, c.HasSynced, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, c.CheckLiveness(time.Minute))
+
+	// The fake clientset sends no bookmarks, so the cache goes idle.
+	require.Eventually(t, func() bool { return c.CheckLiveness(20*time.Millisecond) != nil },
+		5*time.Second, 10*time.Millisecond)
+
+	c.activity.watchFailed(errors.New("connection refused"))
+	require.ErrorContains(t, c.CheckLiveness(20*time.Millisecond), "connection refused")
+
+	_, err := clientset.AppsV1().Deployments("default").Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return c.CheckLiveness(time.Second) == nil },
+		5*time.Second, 10*time.Millisecond)
+}
+
+func TestDeploymentCache_CheckLivenessIgnoresResyncs(t *testing.T) {
+	t.Parallel()
+	clientset := fake.NewClientset(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", ResourceVersion: "1"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"default"}, ResyncPeriod: time.Second})
+	var resyncs atomic.Int32
+	_, err := c.deployments.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			if isResync(oldObj, newObj) {
+				resyncs.Add(1)
+			}
+		},
+	})
+	require.NoError(t, err)
+	c.Start(ctx)
+	require.Eventually(t, c.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	// Resyncs of the non-empty cache arrive more often than maxIdle, but they are not progress.
+	require.Eventually(t, func() bool { return c.CheckLiveness(1500*time.Millisecond) != nil },
+		5*time.Second, 50*time.Millisecond)
+	require.Positive(t, resyncs.Load())
+}
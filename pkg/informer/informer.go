@@ -2,77 +2,417 @@ package informer
 
 import (
 	"context"
-	"os"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/informers"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
 )
 
-type DeploymentInformer struct{}
+// DeploymentLister reads Deployments from the informer cache. An empty namespace lists all watched namespaces.
+type DeploymentLister interface {
+	List(namespace string) []*appsv1.Deployment
+	Get(namespace, name string) (*appsv1.Deployment, bool)
+	ReplicaSets(deployment *appsv1.Deployment) []*appsv1.ReplicaSet
+	Watch(lastEventID string) *Subscription
+}
+
+// ownerUIDIndex indexes ReplicaSets by the UIDs of their owners.
+const ownerUIDIndex = "ownerUID"
+
+const (
+	// DefaultResyncPeriod is how often the informers replay their cache to event handlers.
+	DefaultResyncPeriod = 25 * time.Second
+	// DefaultEventHistorySize is the number of events kept for resuming watch subscribers.
+	DefaultEventHistorySize = 1024
+)
 
-func (d *DeploymentInformer) GetDeploymentsNames() []string {
-	return GetDeploymentsNames()
+// Options configures a DeploymentCache.
+type Options struct {
+	// Namespaces to watch. Empty, or containing metav1.NamespaceAll, watches all namespaces.
+	Namespaces []string
+	// ResyncPeriod defaults to DefaultResyncPeriod.
+	ResyncPeriod time.Duration
+	// EventHistorySize defaults to DefaultEventHistorySize.
+	EventHistorySize int
+	// Recorder records stalled rollouts on Deployments. Without it they are only logged.
+	Recorder *events.Recorder
+	// Selection limits the Deployments that are served and published. Pass
+	// the controller's selection so that both see the same Deployments.
+	Selection selection.Config
 }
 
-type DeploymentLister interface {
-	GetDeploymentsNames() []string
+// DeploymentCache watches Deployments and their ReplicaSets and serves them from memory.
+// A single informer factory feeds one shared cache. It watches the namespace
+// when exactly one is configured and the whole cluster otherwise, since the
+// API server cannot list several namespaces in one request; objects outside
+// the configured namespaces are then skipped when listing and publishing.
+// Lookups by namespace go through the informers' namespace index.
+type DeploymentCache struct {
+	factory     informers.SharedInformerFactory
+	deployments cache.SharedIndexInformer
+	replicaSets cache.SharedIndexInformer
+	// namespaces is the normalized namespace list; [metav1.NamespaceAll] watches everything.
+	namespaces  []string
+	broadcaster *Broadcaster
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+	activity    activity
+	resync      time.Duration
+	recorder    *events.Recorder
+	selection   selection.Config
 }
 
-var informer cache.SharedIndexInformer
+var _ DeploymentLister = &DeploymentCache{}
 
-func StartDeploymentInformer(ctx context.Context, clientset *kubernetes.Clientset) {
-	factory := informers.NewSharedInformerFactoryWithOptions(
+// NewDeploymentCache creates a DeploymentCache. No requests are made until Start is called.
+func NewDeploymentCache(clientset kubernetes.Interface, opts Options) *DeploymentCache {
+	if opts.ResyncPeriod == 0 {
+		opts.ResyncPeriod = DefaultResyncPeriod
+	}
+	if opts.EventHistorySize == 0 {
+		opts.EventHistorySize = DefaultEventHistorySize
+	}
+	c := &DeploymentCache{
+		namespaces:  NormalizeNamespaces(opts.Namespaces),
+		broadcaster: NewBroadcaster(opts.EventHistorySize),
+		stopCh:      make(chan struct{}),
+		activity:    activity{versions: map[cache.SharedIndexInformer]string{}},
+		resync:      opts.ResyncPeriod,
+		recorder:    opts.Recorder,
+		selection:   opts.Selection,
+	}
+	scope := metav1.NamespaceAll
+	if len(c.namespaces) == 1 {
+		scope = c.namespaces[0]
+	}
+	// Excluded namespaces and the label selector are left out by the API
+	// server. The labels only apply to Deployments, since their ReplicaSets
+	// carry the pod template labels instead.
+	fieldSelector := c.selection.FieldSelector().String()
+	c.factory = informers.NewSharedInformerFactoryWithOptions(
 		clientset,
-		25*time.Second,
-		informers.WithNamespace("default"),
+		opts.ResyncPeriod,
+		informers.WithNamespace(scope),
 		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
-			options.FieldSelector = fields.Everything().String()
+			options.FieldSelector = fieldSelector
 		}),
 	)
-	informer = factory.Apps().V1().Deployments().Informer()
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	labelSelector := c.selection.LabelSelector().String()
+	c.deployments = c.factory.InformerFor(&appsv1.Deployment{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+		return appsinformers.NewFilteredDeploymentInformer(client, scope, resync, indexers, func(options *metav1.ListOptions) {
+			options.FieldSelector = fieldSelector
+			options.LabelSelector = labelSelector
+		})
+	})
+	c.addInformers()
+	return c
+}
+
+// NormalizeNamespaces deduplicates the namespace list. An empty list or one
+// containing metav1.NamespaceAll means all namespaces and yields [metav1.NamespaceAll].
+func NormalizeNamespaces(namespaces []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, ns := range namespaces {
+		if ns == metav1.NamespaceAll {
+			return []string{metav1.NamespaceAll}
+		}
+		if !seen[ns] {
+			seen[ns] = true
+			out = append(out, ns)
+		}
+	}
+	if len(out) == 0 {
+		return []string{metav1.NamespaceAll}
+	}
+	return out
+}
+
+// addInformers adds the event handlers and registers the ReplicaSet informer
+// with the factory. Both informers have a cache.NamespaceIndex indexer.
+func (c *DeploymentCache) addInformers() {
+	c.deployments.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
+			c.activity.touch()
+			if !c.selected(obj) {
+				return
+			}
 			log.Info().Msgf("Deployment added: %s", getDeploymentName(obj))
+			c.publish(EventAdded, obj)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			log.Info().Msgf("Deployment updated: %s", getDeploymentName(newObj))
+			// Resyncs replay the cache on a local timer and keep coming when the watch is dead.
+			if isResync(oldObj, newObj) {
+				return
+			}
+			c.activity.touch()
+			// Deployments entering or leaving the selection are reported as added or deleted.
+			switch wasSelected, selected := c.selected(oldObj), c.selected(newObj); {
+			case wasSelected && selected:
+				log.Info().Msgf("Deployment updated: %s", getDeploymentName(newObj))
+				c.recordRolloutStall(oldObj, newObj)
+				c.publish(EventModified, newObj)
+			case selected:
+				log.Info().Msgf("Deployment selected: %s", getDeploymentName(newObj))
+				c.publish(EventAdded, newObj)
+			case wasSelected:
+				log.Info().Msgf("Deployment deselected: %s", getDeploymentName(newObj))
+				c.publish(EventDeleted, newObj)
+			}
 		},
 		DeleteFunc: func(obj interface{}) {
+			c.activity.touch()
+			if !c.selected(obj) {
+				return
+			}
 			log.Info().Msgf("Deployment deleted: %s", getDeploymentName(obj))
+			c.publish(EventDeleted, obj)
 		},
 	})
-	log.Info().Msg("Starting deployment informer...")
+	c.replicaSets = c.factory.Apps().V1().ReplicaSets().Informer()
+	for _, inf := range c.informers() {
+		if err := inf.informer.SetWatchErrorHandlerWithContext(c.watchErrorHandler); err != nil {
+			log.Error().Err(err).Msg("Failed to set informer watch error handler")
+		}
+	}
+	if err := c.replicaSets.AddIndexers(cache.Indexers{ownerUIDIndex: indexByOwnerUID}); err != nil {
+		log.Error().Err(err).Msg("Failed to add ReplicaSet owner index")
+	}
+}
+
+// namedInformer names an informer's resource in sync errors.
+type namedInformer struct {
+	resource string
+	informer cache.SharedIndexInformer
+}
+
+func (c *DeploymentCache) informers() []namedInformer {
+	return []namedInformer{{"deployments", c.deployments}, {"replicasets", c.replicaSets}}
+}
+
+// Start begins watching in the background. The informers run until Stop is called or ctx is done.
+func (c *DeploymentCache) Start(ctx context.Context) {
+	log.Info().Strs("namespaces", c.namespaceNames()).Msg("Starting deployment informer...")
+	c.activity.touch()
+	c.factory.Start(c.stopCh)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Stop()
+		case <-c.stopCh:
+		}
+	}()
+}
 
-	factory.Start(ctx.Done())
-	for t, ok := range factory.WaitForCacheSync(ctx.Done()) {
-		if !ok {
-			log.Error().Msgf("Failed to sync informer foe %v", t)
-			os.Exit(1)
+// Run starts the cache and waits up to syncTimeout for it to sync. It then blocks
+// until ctx is done and returns nil once the informers have stopped, or returns
+// the sync error after stopping the cache.
+// Errors are returned rather than handled so the caller decides how to fail.
+func (c *DeploymentCache) Run(ctx context.Context, syncTimeout time.Duration) error {
+	c.Start(ctx)
+	if err := c.WaitForSync(ctx, syncTimeout); err != nil {
+		c.Stop()
+		if ctx.Err() != nil {
+			// Shutting down before the initial sync is not a failure.
+			return nil
 		}
+		return err
 	}
 	log.Info().Msg("Deployment informer cache synced. Watching for events...")
 	<-ctx.Done()
+	c.Stop()
+	return nil
 }
 
-func GetDeploymentsNames() []string {
-	var names []string
-	if informer == nil {
-		return names
+// WaitForSync blocks until every informer has synced. It fails when ctx is done,
+// the cache is stopped, or the timeout (if positive) expires, naming the
+// resource and namespace whose informer has not synced.
+func (c *DeploymentCache) WaitForSync(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	for _, obj := range informer.GetStore().List() {
-		if deployment, ok := obj.(*appsv1.Deployment); ok {
-			names = append(names, deployment.Name)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+		case <-c.stopCh:
+		}
+	}()
+
+	for _, inf := range c.informers() {
+		if cache.WaitForCacheSync(done, inf.informer.HasSynced) {
+			continue
+		}
+		scope := c.scope()
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return fmt.Errorf("timed out after %s waiting for %s informer in %s to sync", timeout, inf.resource, scope)
+		case ctx.Err() != nil:
+			return fmt.Errorf("stopped waiting for %s informer in %s to sync: %w", inf.resource, scope, ctx.Err())
+		default:
+			return fmt.Errorf("deployment cache stopped before %s informer in %s synced", inf.resource, scope)
 		}
 	}
+	return nil
+}
+
+// HasSynced reports whether every informer has completed its initial list.
+func (c *DeploymentCache) HasSynced() bool {
+	return c.deployments.HasSynced() && c.replicaSets.HasSynced()
+}
+
+// ResyncPeriod returns the period the informers replay their cache with.
+func (c *DeploymentCache) ResyncPeriod() time.Duration {
+	return c.resync
+}
+
+// Stop shuts the informers down and closes all watch subscriptions. It is safe to call more than once.
+func (c *DeploymentCache) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+		c.factory.Shutdown()
+		c.broadcaster.Shutdown()
+		log.Info().Msg("Deployment informer stopped")
+	})
+}
+
+// List returns the cached Deployments in the namespace, or in every
+// watched namespace when namespace is empty.
+func (c *DeploymentCache) List(namespace string) []*appsv1.Deployment {
+	var objs []any
+	if namespace == metav1.NamespaceAll {
+		objs = c.deployments.GetStore().List()
+	} else if c.watches(namespace) {
+		var err error
+		objs, err = c.deployments.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to look up Deployments by namespace")
+		}
+	}
+	var deployments []*appsv1.Deployment
+	for _, obj := range objs {
+		if deployment, ok := obj.(*appsv1.Deployment); ok && c.selected(deployment) {
+			deployments = append(deployments, deployment)
+		}
+	}
+	return deployments
+}
+
+// Get returns the cached Deployment with the given namespace and name.
+func (c *DeploymentCache) Get(namespace, name string) (*appsv1.Deployment, bool) {
+	obj, exists, err := c.deployments.GetStore().GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, false
+	}
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok || !c.selected(deployment) {
+		return nil, false
+	}
+	return deployment, true
+}
+
+// ReplicaSets returns the cached ReplicaSets controlled by the given Deployment.
+func (c *DeploymentCache) ReplicaSets(deployment *appsv1.Deployment) []*appsv1.ReplicaSet {
+	var replicaSets []*appsv1.ReplicaSet
+	objs, err := c.replicaSets.GetIndexer().ByIndex(ownerUIDIndex, string(deployment.UID))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up ReplicaSets by owner")
+		return replicaSets
+	}
+	for _, obj := range objs {
+		if rs, ok := obj.(*appsv1.ReplicaSet); ok && metav1.IsControlledBy(rs, deployment) {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	return replicaSets
+}
+
+// Watch subscribes to Deployment changes, resuming after the event with lastEventID when possible.
+func (c *DeploymentCache) Watch(lastEventID string) *Subscription {
+	return c.broadcaster.Subscribe(lastEventID)
+}
+
+// watches reports whether the namespace is one of the configured namespaces.
+func (c *DeploymentCache) watches(namespace string) bool {
+	return c.namespaces[0] == metav1.NamespaceAll || slices.Contains(c.namespaces, namespace)
+}
+
+// scope describes the watched namespaces in sync errors.
+func (c *DeploymentCache) scope() string {
+	if c.namespaces[0] == metav1.NamespaceAll {
+		return "all namespaces"
+	}
+	quoted := make([]string, len(c.namespaces))
+	for i, ns := range c.namespaces {
+		quoted[i] = strconv.Quote(ns)
+	}
+	if len(quoted) == 1 {
+		return "namespace " + quoted[0]
+	}
+	return "namespaces " + strings.Join(quoted, ", ")
+}
+
+func (c *DeploymentCache) namespaceNames() []string {
+	if c.namespaces[0] == metav1.NamespaceAll {
+		return []string{"*"}
+	}
+	names := slices.Clone(c.namespaces)
+	sort.Strings(names)
 	return names
 }
 
+// selected reports whether an informer callback object is a selected
+// Deployment in one of the watched namespaces.
+func (c *DeploymentCache) selected(obj any) bool {
+	d, ok := deploymentFrom(obj)
+	return ok && c.watches(d.Namespace) && c.selection.Matches(d)
+}
+
+func (c *DeploymentCache) publish(t EventType, obj any) {
+	if ev, ok := newDeploymentEvent(t, obj); ok {
+		c.broadcaster.Publish(ev)
+	}
+}
+
+func indexByOwnerUID(obj any) ([]string, error) {
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, nil
+	}
+	var uids []string
+	for _, ref := range meta.GetOwnerReferences() {
+		uids = append(uids, string(ref.UID))
+	}
+	return uids, nil
+}
+
+// isResync reports whether an update was produced by a periodic resync rather than a change.
+func isResync(oldObj, newObj any) bool {
+	oldMeta, okOld := oldObj.(metav1.Object)
+	newMeta, okNew := newObj.(metav1.Object)
+	return okOld && okNew && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
+
 func getDeploymentName(obj any) string {
 	if deployment, ok := obj.(metav1.Object); ok {
 		return deployment.GetName()
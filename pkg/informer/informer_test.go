@@ -2,70 +2,42 @@ package informer
 
 import (
 	"context"
-	"sync"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/informers"
-	"k8s.io/client-go/tools/cache"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/selection"
 	testutil "github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
 )
 
-func TestStartDeploymentInformer(t *testing.T) {
+func TestDeploymentCache_Envtest(t *testing.T) {
 	_, clientset, cleanup := testutil.SetupEnv(t)
 	defer cleanup()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"default"}})
+	c.Start(ctx)
+	defer c.Stop()
 
-	// Patch log to write to a buffer or just rely on test output
-	added := make(chan string, 2)
+	require.Eventually(t, c.HasSynced, 5*time.Second, 50*time.Millisecond)
 
-	// Patch event handler for test
-	factory := informers.NewSharedInformerFactoryWithOptions(
-		clientset,
-		30*time.Second,
-		informers.WithNamespace("default"),
-	)
-	informer := factory.Apps().V1().Deployments().Informer()
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj any) {
-			if d, ok := obj.(metav1.Object); ok {
-				added <- d.GetName()
-			}
-		},
-	})
-
-	go func() {
-		defer wg.Done()
-		factory.Start(ctx.Done())
-		factory.WaitForCacheSync(ctx.Done())
-		<-ctx.Done()
-	}()
-
-	// Wait for events
-	found := map[string]bool{}
-	for range 2 {
-		select {
-		case name := <-added:
-			found[name] = true
-		case <-time.After(5 * time.Second):
-			t.Fatal("timed out waiting for deployment add events")
-		}
+	names := map[string]bool{}
+	for _, d := range c.List("default") {
+		names[d.Name] = true
 	}
-
-	require.True(t, found["sample-deployment-1"])
-	require.True(t, found["sample-deployment-2"])
-
-	cancel()
-	wg.Wait()
+	require.True(t, names["sample-deployment-1"])
+	require.True(t, names["sample-deployment-2"])
 
 	//t.Log("Sleeping for 5 minutes to allow manual kubectl inspection of envtest cluster...")
 	//time.Sleep(5 * time.Minute)
@@ -84,58 +56,315 @@ func TestGetDeploymentName(t *testing.T) {
 	}
 }
 
-func TestStartDeploymentInformer_CoversFunction(t *testing.T) {
+func TestDeploymentCache_Run(t *testing.T) {
 	_, clientset, cleanup := testutil.SetupEnv(t)
 	defer cleanup()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
-	// Run StartDeploymentInformer in a goroutine
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"default"}})
+	done := make(chan struct{})
 	go func() {
-		StartDeploymentInformer(ctx, clientset)
+		if err := c.Run(ctx, 5*time.Second); err != nil {
+			t.Error(err)
+		}
+		close(done)
 	}()
 
-	// Give the informer some time to start and process events
-	time.Sleep(1 * time.Second)
+	require.Eventually(t, c.HasSynced, 5*time.Second, 50*time.Millisecond)
 	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
 }
 
-func TestGetDeploymentsNames(t *testing.T) {
-	_, clientset, cleanup := testutil.SetupEnv(t)
-	defer cleanup()
+func TestIndexByOwnerUID(t *testing.T) {
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{{UID: "uid-1"}, {UID: "uid-2"}},
+		},
+	}
+	uids, err := indexByOwnerUID(rs)
+	require.NoError(t, err)
+	require.Equal(t, []string{"uid-1", "uid-2"}, uids)
 
+	uids, err = indexByOwnerUID("not-an-object")
+	require.NoError(t, err)
+	require.Empty(t, uids)
+}
+
+func TestNormalizeNamespaces(t *testing.T) {
+	require.Equal(t, []string{""}, NormalizeNamespaces(nil))
+	require.Equal(t, []string{""}, NormalizeNamespaces([]string{"a", ""}))
+	require.Equal(t, []string{"a", "b"}, NormalizeNamespaces([]string{"a", "b", "a"}))
+}
+
+func TestDeploymentCache_MultipleNamespaces(t *testing.T) {
+	dep := func(namespace, name string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(namespace + "-" + name)}}
+	}
+	web := dep("team-a", "web")
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "web-1",
+		Namespace:       "team-a",
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(web, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+	}}
+
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       int
+	}{
+		{"selected namespaces", []string{"team-a", "team-b"}, 3},
+		{"all namespaces", []string{metav1.NamespaceAll}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			clientset := fake.NewClientset(web, dep("team-a", "api"), dep("team-b", "db"), dep("team-c", "cache"), rs)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := NewDeploymentCache(clientset, Options{Namespaces: tt.namespaces})
+			c.Start(ctx)
+			defer c.Stop()
+			require.Eventually(t, c.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+			require.Len(t, c.List(metav1.NamespaceAll), tt.want)
+			require.Len(t, c.List("team-a"), 2)
+			require.Len(t, c.List("team-b"), 1)
+			got, ok := c.Get("team-b", "db")
+			require.True(t, ok)
+			require.Equal(t, "db", got.Name)
+			require.Len(t, c.ReplicaSets(web), 1)
+
+			_, ok = c.Get("team-c", "cache")
+			require.Equal(t, tt.want == 4, ok)
+			_, ok = c.Get("team-a", "missing")
+			require.False(t, ok)
+			require.Len(t, c.List("team-c"), tt.want-3)
+
+			// One shared cache: a single list per resource, whatever the number of namespaces.
+			lists := map[string]int{}
+			for _, action := range clientset.Actions() {
+				if action.GetVerb() == "list" {
+					lists[action.GetResource().Resource]++
+				}
+			}
+			require.Equal(t, map[string]int{"deployments": 1, "replicasets": 1}, lists)
+		})
+	}
+}
+
+func TestDeploymentCache_WatchAndStop(t *testing.T) {
+	t.Parallel()
+	clientset := fake.NewClientset()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	factory := informers.NewSharedInformerFactoryWithOptions(
-		clientset,
-		30*time.Second,
-		informers.WithNamespace("default"),
-	)
-	informer := factory.Apps().V1().Deployments().Informer()
-	deployment1 := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "sample-deployment-1",
-			Namespace: "default",
-		},
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"default"}})
+	sub := c.Watch("")
+	c.Start(ctx)
+	require.Eventually(t, c.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	_, err := clientset.AppsV1().Deployments("default").Create(ctx, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	select {
+	case ev := <-sub.Events:
+		require.Equal(t, EventAdded, ev.Type)
+		require.Equal(t, "web", ev.Deployment.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the ADDED event")
 	}
-	deployment2 := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "sample-deployment-2",
-			Namespace: "default",
-		},
+
+	c.Stop()
+	c.Stop()
+	_, ok := <-sub.Events
+	require.False(t, ok, "Stop must close watch subscriptions")
+}
+
+func TestDeploymentCache_StopsWithContext(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := NewDeploymentCache(fake.NewClientset(), Options{})
+	sub := c.Watch("")
+	c.Start(ctx)
+	cancel()
+
+	select {
+	case _, ok := <-sub.Events:
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache did not stop after the context was cancelled")
 	}
+}
 
-	informer.GetStore().Add(deployment1)
-	informer.GetStore().Add(deployment2)
+func TestDeploymentCache_WaitForSyncTimeout(t *testing.T) {
+	t.Parallel()
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "replicasets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("replicasets are forbidden")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	go func() {
-		factory.Start(ctx.Done())
-		factory.WaitForCacheSync(ctx.Done())
-	}()
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"payments"}})
+	err := c.Run(ctx, 300*time.Millisecond)
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "replicasets")
+	require.Contains(t, err.Error(), `namespace "payments"`)
+	require.Contains(t, err.Error(), "timed out after 300ms")
+
+	sub := c.Watch("")
+	_, ok := <-sub.Events
+	require.False(t, ok, "a failed Run must stop the cache")
+}
+
+func TestDeploymentCache_RunCancelledBeforeSync(t *testing.T) {
+	t.Parallel()
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	c := NewDeploymentCache(clientset, Options{})
+	require.NoError(t, c.Run(ctx, 0))
+}
+
+func TestDeploymentCache_WaitForSyncAfterStop(t *testing.T) {
+	t.Parallel()
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+	c := NewDeploymentCache(clientset, Options{})
+	c.Start(context.Background())
+	c.Stop()
+
+	err := c.WaitForSync(context.Background(), 0)
+	require.ErrorContains(t, err, "deployments informer in all namespaces")
+}
+
+func TestDeploymentCache_ClusterWideWatchOfSeveralNamespaces(t *testing.T) {
+	t.Parallel()
+	dep := func(namespace, name string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+	clientset := fake.NewClientset(dep("team-a", "web"), dep("team-c", "cache"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewDeploymentCache(clientset, Options{Namespaces: []string{"team-a", "team-b"}})
+	sub := c.Watch("")
+	c.Start(ctx)
+	defer c.Stop()
+	require.Eventually(t, c.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	// The API server cannot list two namespaces at once, so the whole cluster is watched...
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "list" {
+			require.Equal(t, metav1.NamespaceAll, action.GetNamespace(), action.GetResource().Resource)
+		}
+	}
+	_, err := clientset.AppsV1().Deployments("team-c").Create(ctx, dep("team-c", "queue"), metav1.CreateOptions{})
+	require.NoError(t, err)
+	_, err = clientset.AppsV1().Deployments("team-b").Create(ctx, dep("team-b", "db"), metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// ...but Deployments of other namespaces are neither served nor published.
+	var published []string
+	for len(published) < 2 {
+		select {
+		case ev := <-sub.Events:
+			published = append(published, ev.Deployment.Namespace+"/"+ev.Deployment.Name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %v", published)
+		}
+	}
+	require.Equal(t, []string{"team-a/web", "team-b/db"}, published)
+	require.Eventually(t, func() bool { return len(c.List(metav1.NamespaceAll)) == 2 }, 5*time.Second, 10*time.Millisecond)
+	require.Empty(t, c.List("team-c"))
+	_, ok := c.Get("team-c", "queue")
+	require.False(t, ok)
+}
+
+func TestDeploymentCache_Selection(t *testing.T) {
+	t.Parallel()
+	dep := func(namespace, name string, labels, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: annotations}}
+	}
+	frontend := map[string]string{"tier": "frontend"}
+	clientset := fake.NewClientset(
+		dep("team-a", "web", frontend, nil),
+		dep("team-a", "db", map[string]string{"tier": "backend"}, nil),
+		dep("team-a", "legacy", frontend, map[string]string{selection.IgnoreAnnotation: "true"}),
+		dep("kube-system", "dns", frontend, nil),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewDeploymentCache(clientset, Options{Selection: selection.Config{
+		ExcludedNamespaces: []string{"kube-system"},
+		Selector:           labels.SelectorFromSet(frontend),
+	}})
+	sub := c.Watch("")
+	c.Start(ctx)
+	defer c.Stop()
+	require.Eventually(t, c.HasSynced, 5*time.Second, 10*time.Millisecond)
+
+	deployments := c.List(metav1.NamespaceAll)
+	require.Len(t, deployments, 1)
+	require.Equal(t, "web", deployments[0].Name)
+	_, ok := c.Get("team-a", "legacy")
+	require.False(t, ok)
+	_, ok = c.Get("kube-system", "dns")
+	require.False(t, ok)
+
+	// The API server filters by labels and namespace; ReplicaSets are only filtered by namespace.
+	restrictions := map[string]k8stesting.ListRestrictions{}
+	for _, action := range clientset.Actions() {
+		if list, ok := action.(k8stesting.ListAction); ok {
+			restrictions[action.GetResource().Resource] = list.GetListRestrictions()
+		}
+	}
+	require.Equal(t, "tier=frontend", restrictions["deployments"].Labels.String())
+	require.Equal(t, "metadata.namespace!=kube-system", restrictions["deployments"].Fields.String())
+	require.True(t, restrictions["replicasets"].Labels.Empty())
+	require.Equal(t, "metadata.namespace!=kube-system", restrictions["replicasets"].Fields.String())
+
+	next := func() DeploymentEvent {
+		t.Helper()
+		select {
+		case ev := <-sub.Events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+			return DeploymentEvent{}
+		}
+	}
+	require.Equal(t, "web", next().Deployment.Name, "only selected Deployments are published")
 
-	names := GetDeploymentsNames()
+	// Opting out is reported as a deletion, opting back in as an addition.
+	web := dep("team-a", "web", frontend, map[string]string{selection.IgnoreAnnotation: "true"})
+	web.ResourceVersion = "2"
+	_, err := clientset.AppsV1().Deployments("team-a").Update(ctx, web, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	ev := next()
+	require.Equal(t, EventDeleted, ev.Type)
+	require.Equal(t, "web", ev.Deployment.Name)
 
-	require.ElementsMatch(t, []string{"sample-deployment-1", "sample-deployment-2"}, names)
+	web.Annotations = nil
+	web.ResourceVersion = "3"
+	_, err = clientset.AppsV1().Deployments("team-a").Update(ctx, web, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	require.Equal(t, EventAdded, next().Type)
 }
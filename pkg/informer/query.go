@@ -0,0 +1,207 @@
+package informer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SortField is the order in which Deployments are listed.
+type SortField string
+
+const (
+	SortByName              SortField = "name"
+	SortByCreationTimestamp SortField = "creationTimestamp"
+	SortByReplicas          SortField = "replicas"
+)
+
+// ParseSortField validates a sort order; an empty string sorts by namespace and name.
+func ParseSortField(s string) (SortField, error) {
+	switch SortField(s) {
+	case "", SortByName:
+		return SortByName, nil
+	case SortByCreationTimestamp, SortByReplicas:
+		return SortField(s), nil
+	default:
+		return "", fmt.Errorf("unknown sort field %q: expected %s, %s or %s", s, SortByName, SortByCreationTimestamp, SortByReplicas)
+	}
+}
+
+// DeploymentFilter selects Deployments from the informer cache. Zero-valued fields match everything.
+type DeploymentFilter struct {
+	Namespace string
+	Selector  labels.Selector
+	Image     string
+	Ready     *bool
+}
+
+// Matches reports whether the Deployment satisfies every criterion of the filter.
+func (f DeploymentFilter) Matches(d *appsv1.Deployment) bool {
+	if f.Namespace != "" && d.Namespace != f.Namespace {
+		return false
+	}
+	if f.Selector != nil && !f.Selector.Matches(labels.Set(d.Labels)) {
+		return false
+	}
+	if f.Image != "" && !hasImage(d, f.Image) {
+		return false
+	}
+	if f.Ready != nil && IsDeploymentReady(d) != *f.Ready {
+		return false
+	}
+	return true
+}
+
+// FilterDeployments returns the Deployments matching the filter, preserving their order.
+func FilterDeployments(deployments []*appsv1.Deployment, f DeploymentFilter) []*appsv1.Deployment {
+	filtered := make([]*appsv1.Deployment, 0, len(deployments))
+	for _, d := range deployments {
+		if f.Matches(d) {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
+// IsDeploymentReady reports whether the latest spec was observed and all desired replicas are updated, ready and available.
+func IsDeploymentReady(d *appsv1.Deployment) bool {
+	desired := desiredReplicas(d)
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == desired &&
+		d.Status.ReadyReplicas == desired &&
+		d.Status.AvailableReplicas == desired
+}
+
+// hasImage reports whether any container uses the image, compared either
+// verbatim or by repository when want carries no tag or digest.
+func hasImage(d *appsv1.Deployment, want string) bool {
+	for _, image := range deploymentImages(d) {
+		if image == want || imageRepository(image) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// imageRepository strips the tag and digest from an image reference.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// SortDeployments orders Deployments by the given field in place. Ties are broken
+// by namespace and name, so the order is total and stable across calls.
+func SortDeployments(deployments []*appsv1.Deployment, field SortField) {
+	sort.Slice(deployments, func(i, j int) bool {
+		return deploymentLess(deployments[i], deployments[j], field)
+	})
+}
+
+func deploymentLess(a, b *appsv1.Deployment, field SortField) bool {
+	switch field {
+	case SortByCreationTimestamp:
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+	case SortByReplicas:
+		if ra, rb := desiredReplicas(a), desiredReplicas(b); ra != rb {
+			return ra < rb
+		}
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// continueToken is the decoded form of the opaque token that resumes a listing.
+// It records the sort key of the last returned item rather than an offset, so
+// pages stay consistent when Deployments are added or removed between requests.
+type continueToken struct {
+	Sort              SortField   `json:"s"`
+	Namespace         string      `json:"ns"`
+	Name              string      `json:"n"`
+	CreationTimestamp metav1.Time `json:"t,omitempty"`
+	Replicas          int32       `json:"r,omitempty"`
+}
+
+// Paginate returns up to limit Deployments that follow the position encoded in
+// continueFrom, together with the token for the next page. The input must
+// already be sorted by field. A non-positive limit returns everything that is left.
+func Paginate(sorted []*appsv1.Deployment, field SortField, limit int, continueFrom string) ([]*appsv1.Deployment, string, error) {
+	start := 0
+	if continueFrom != "" {
+		cursor, err := decodeContinueToken(continueFrom, field)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(sorted), func(i int) bool {
+			return deploymentLess(cursor, sorted[i], field)
+		})
+	}
+	rest := sorted[start:]
+	if limit <= 0 || len(rest) <= limit {
+		return rest, "", nil
+	}
+	page := rest[:limit]
+	next, err := encodeContinueToken(page[len(page)-1], field)
+	if err != nil {
+		return nil, "", err
+	}
+	return page, next, nil
+}
+
+func encodeContinueToken(last *appsv1.Deployment, field SortField) (string, error) {
+	raw, err := json.Marshal(continueToken{
+		Sort:              field,
+		Namespace:         last.Namespace,
+		Name:              last.Name,
+		CreationTimestamp: last.CreationTimestamp,
+		Replicas:          desiredReplicas(last),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeContinueToken turns a token back into a Deployment carrying just the sort keys.
+func decodeContinueToken(token string, field SortField) (*appsv1.Deployment, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continue token")
+	}
+	var ct continueToken
+	if err := json.Unmarshal(raw, &ct); err != nil {
+		return nil, fmt.Errorf("invalid continue token")
+	}
+	if ct.Sort != field {
+		return nil, fmt.Errorf("continue token was issued for sort=%s, not sort=%s", ct.Sort, field)
+	}
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         ct.Namespace,
+			Name:              ct.Name,
+			CreationTimestamp: ct.CreationTimestamp,
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &ct.Replicas},
+	}, nil
+}
+
+func desiredReplicas(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas != nil {
+		return *d.Spec.Replicas
+	}
+	return 1
+}
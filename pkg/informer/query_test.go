@@ -0,0 +1,172 @@
+package informer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	testutil "github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
+)
+
+func newTestDeployment(namespace, name string, lbls map[string]string, image string, replicas, ready int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: lbls},
+		Spec:       testutil.NewDeploymentSpec(replicas, map[string]string{"app": name}, image),
+		Status: appsv1.DeploymentStatus{
+			UpdatedReplicas:   replicas,
+			ReadyReplicas:     ready,
+			AvailableReplicas: ready,
+		},
+	}
+}
+
+func TestDeploymentFilter_Matches(t *testing.T) {
+	dep := newTestDeployment("shop", "api", map[string]string{"team": "payments"}, "ghcr.io/acme/api:1.2.3", 3, 2)
+	yes, no := true, false
+
+	tests := []struct {
+		name   string
+		filter DeploymentFilter
+		want   bool
+	}{
+		{"empty filter", DeploymentFilter{}, true},
+		{"namespace match", DeploymentFilter{Namespace: "shop"}, true},
+		{"namespace mismatch", DeploymentFilter{Namespace: "default"}, false},
+		{"selector match", DeploymentFilter{Selector: labels.SelectorFromSet(labels.Set{"team": "payments"})}, true},
+		{"selector mismatch", DeploymentFilter{Selector: labels.SelectorFromSet(labels.Set{"team": "search"})}, false},
+		{"image exact", DeploymentFilter{Image: "ghcr.io/acme/api:1.2.3"}, true},
+		{"image repository", DeploymentFilter{Image: "ghcr.io/acme/api"}, true},
+		{"image mismatch", DeploymentFilter{Image: "ghcr.io/acme/api:1.2.4"}, false},
+		{"not ready", DeploymentFilter{Ready: &no}, true},
+		{"ready", DeploymentFilter{Ready: &yes}, false},
+		{"combined", DeploymentFilter{Namespace: "shop", Ready: &no, Image: "ghcr.io/acme/api"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.filter.Matches(dep))
+		})
+	}
+}
+
+func TestFilterDeployments(t *testing.T) {
+	deployments := []*appsv1.Deployment{
+		newTestDeployment("default", "a", map[string]string{"team": "payments"}, "nginx", 1, 1),
+		newTestDeployment("default", "b", map[string]string{"team": "search"}, "nginx", 1, 1),
+		newTestDeployment("default", "c", map[string]string{"team": "payments"}, "nginx", 2, 1),
+	}
+	selector, err := labels.Parse("team=payments")
+	require.NoError(t, err)
+
+	got := FilterDeployments(deployments, DeploymentFilter{Selector: selector})
+
+	require.Len(t, got, 2)
+	require.Equal(t, "a", got[0].Name)
+	require.Equal(t, "c", got[1].Name)
+}
+
+func TestIsDeploymentReady(t *testing.T) {
+	require.True(t, IsDeploymentReady(newTestDeployment("default", "a", nil, "nginx", 2, 2)))
+	require.False(t, IsDeploymentReady(newTestDeployment("default", "a", nil, "nginx", 2, 1)))
+
+	stale := newTestDeployment("default", "a", nil, "nginx", 2, 2)
+	stale.Generation = 2
+	stale.Status.ObservedGeneration = 1
+	require.False(t, IsDeploymentReady(stale))
+}
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                         "nginx",
+		"nginx:1.27":                    "nginx",
+		"localhost:5000/app":            "localhost:5000/app",
+		"localhost:5000/app:v1":         "localhost:5000/app",
+		"ghcr.io/acme/api@sha256:abcd":  "ghcr.io/acme/api",
+		"ghcr.io/acme/api:1@sha256:abc": "ghcr.io/acme/api",
+	}
+	for image, want := range tests {
+		require.Equal(t, want, imageRepository(image), image)
+	}
+}
+
+func TestParseSortField(t *testing.T) {
+	for in, want := range map[string]SortField{
+		"":                  SortByName,
+		"name":              SortByName,
+		"creationTimestamp": SortByCreationTimestamp,
+		"replicas":          SortByReplicas,
+	} {
+		got, err := ParseSortField(in)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	_, err := ParseSortField("age")
+	require.Error(t, err)
+}
+
+func TestSortDeployments(t *testing.T) {
+	deployments := []*appsv1.Deployment{
+		newTestDeployment("b", "x", nil, "nginx", 2, 0),
+		newTestDeployment("a", "y", nil, "nginx", 1, 0),
+		newTestDeployment("a", "x", nil, "nginx", 2, 0),
+	}
+
+	SortDeployments(deployments, SortByName)
+	require.Equal(t, []string{"a/x", "a/y", "b/x"}, keys(deployments))
+
+	SortDeployments(deployments, SortByReplicas)
+	require.Equal(t, []string{"a/y", "a/x", "b/x"}, keys(deployments))
+}
+
+func TestPaginate(t *testing.T) {
+	var deployments []*appsv1.Deployment
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		deployments = append(deployments, newTestDeployment("default", name, nil, "nginx", 1, 1))
+	}
+
+	page, next, err := Paginate(deployments, SortByName, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"default/a", "default/b"}, keys(page))
+	require.NotEmpty(t, next)
+
+	// A deployment removed before the next request must not shift the page boundary.
+	remaining := append([]*appsv1.Deployment{deployments[0]}, deployments[2:]...)
+	page, next, err = Paginate(remaining, SortByName, 2, next)
+	require.NoError(t, err)
+	require.Equal(t, []string{"default/c", "default/d"}, keys(page))
+
+	page, next, err = Paginate(remaining, SortByName, 2, next)
+	require.NoError(t, err)
+	require.Equal(t, []string{"default/e"}, keys(page))
+	require.Empty(t, next)
+
+	page, next, err = Paginate(deployments, SortByName, 0, "")
+	require.NoError(t, err)
+	require.Len(t, page, 5)
+	require.Empty(t, next)
+}
+
+func TestPaginate_InvalidToken(t *testing.T) {
+	deployments := []*appsv1.Deployment{
+		newTestDeployment("default", "a", nil, "nginx", 1, 1),
+		newTestDeployment("default", "b", nil, "nginx", 1, 1),
+	}
+
+	_, _, err := Paginate(deployments, SortByName, 1, "not base64!")
+	require.Error(t, err)
+
+	_, next, err := Paginate(deployments, SortByName, 1, "")
+	require.NoError(t, err)
+	_, _, err = Paginate(deployments, SortByReplicas, 1, next)
+	require.ErrorContains(t, err, "sort=name")
+}
+
+func keys(deployments []*appsv1.Deployment) []string {
+	var out []string
+	for _, d := range deployments {
+		out = append(out, d.Namespace+"/"+d.Name)
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package informer
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ReasonRolloutStalled is recorded on a Deployment when it exceeds its progress deadline.
+const ReasonRolloutStalled = "RolloutStalled"
+
+// progressDeadlineExceeded returns the Progressing condition message once the
+// Deployment controller has given up waiting for the rollout to progress.
+func progressDeadlineExceeded(d *appsv1.Deployment) (string, bool) {
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			return c.Message, true
+		}
+	}
+	return "", false
+}
+
+// recordRolloutStall records an event when an update makes the Deployment exceed
+// its progress deadline. Deployments that were already stalled when the cache
+// started are not reported, so restarts do not repeat the event.
+func (c *DeploymentCache) recordRolloutStall(oldObj, newObj any) {
+	old, okOld := oldObj.(*appsv1.Deployment)
+	d, okNew := newObj.(*appsv1.Deployment)
+	if !okOld || !okNew {
+		return
+	}
+	if _, stalled := progressDeadlineExceeded(old); stalled {
+		return
+	}
+	if message, stalled := progressDeadlineExceeded(d); stalled {
+		c.recorder.Warningf(d, ReasonRolloutStalled, "Rollout of revision %s stalled: %s", d.Annotations["deployment.kubernetes.io/revision"], message)
+	}
+}
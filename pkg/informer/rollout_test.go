@@ -0,0 +1,48 @@
+package informer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/events"
+)
+
+func TestDeploymentCache_RecordRolloutStall(t *testing.T) {
+	progressing := func(status corev1.ConditionStatus, reason string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "web",
+				Namespace:   "default",
+				Annotations: map[string]string{"deployment.kubernetes.io/revision": "4"},
+			},
+			Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+				Type:    appsv1.DeploymentProgressing,
+				Status:  status,
+				Reason:  reason,
+				Message: `ReplicaSet "web-5d4f" has timed out progressing.`,
+			}}},
+		}
+	}
+	inProgress := progressing(corev1.ConditionTrue, "ReplicaSetUpdated")
+	stalled := progressing(corev1.ConditionFalse, "ProgressDeadlineExceeded")
+
+	fake := record.NewFakeRecorder(10)
+	c := &DeploymentCache{recorder: events.NewRecorder(fake)}
+
+	c.recordRolloutStall(inProgress, stalled)
+	require.Equal(t, `Warning RolloutStalled Rollout of revision 4 stalled: ReplicaSet "web-5d4f" has timed out progressing.`, <-fake.Events)
+
+	c.recordRolloutStall(stalled, stalled)
+	c.recordRolloutStall(stalled, inProgress)
+	c.recordRolloutStall(inProgress, inProgress)
+	require.Empty(t, fake.Events)
+
+	require.NotPanics(t, func() {
+		(&DeploymentCache{}).recordRolloutStall(inProgress, stalled)
+	}, "the recorder is optional")
+}
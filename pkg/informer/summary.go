@@ -0,0 +1,127 @@
+package informer
+
+import (
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DeploymentSummary is the JSON representation of a Deployment served by the HTTP API.
+type DeploymentSummary struct {
+	Namespace         string                `json:"namespace"`
+	Name              string                `json:"name"`
+	DesiredReplicas   int32                 `json:"desiredReplicas"`
+	ReadyReplicas     int32                 `json:"readyReplicas"`
+	AvailableReplicas int32                 `json:"availableReplicas"`
+	UpdatedReplicas   int32                 `json:"updatedReplicas"`
+	Images            []string              `json:"images"`
+	Labels            map[string]string     `json:"labels,omitempty"`
+	CreationTimestamp time.Time             `json:"creationTimestamp"`
+	Conditions        []DeploymentCondition `json:"conditions,omitempty"`
+}
+
+// DeploymentCondition is a trimmed down rollout condition of a Deployment.
+type DeploymentCondition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// DeploymentDetail is the JSON representation of a single Deployment together with its ReplicaSets.
+type DeploymentDetail struct {
+	Metadata    metav1.ObjectMeta       `json:"metadata"`
+	Spec        appsv1.DeploymentSpec   `json:"spec"`
+	Status      appsv1.DeploymentStatus `json:"status"`
+	ReplicaSets []ReplicaSetSummary     `json:"replicaSets"`
+}
+
+// ReplicaSetSummary describes a ReplicaSet owned by a Deployment.
+type ReplicaSetSummary struct {
+	Name              string    `json:"name"`
+	Revision          string    `json:"revision,omitempty"`
+	DesiredReplicas   int32     `json:"desiredReplicas"`
+	ReadyReplicas     int32     `json:"readyReplicas"`
+	AvailableReplicas int32     `json:"availableReplicas"`
+	Images            []string  `json:"images"`
+	CreationTimestamp time.Time `json:"creationTimestamp"`
+}
+
+// revisionAnnotation is set by the deployment controller on every ReplicaSet it manages.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// NewDeploymentSummary builds a DeploymentSummary from a Deployment.
+func NewDeploymentSummary(d *appsv1.Deployment) DeploymentSummary {
+	summary := DeploymentSummary{
+		Namespace:         d.Namespace,
+		Name:              d.Name,
+		DesiredReplicas:   desiredReplicas(d),
+		ReadyReplicas:     d.Status.ReadyReplicas,
+		AvailableReplicas: d.Status.AvailableReplicas,
+		UpdatedReplicas:   d.Status.UpdatedReplicas,
+		Images:            deploymentImages(d),
+		Labels:            d.Labels,
+		CreationTimestamp: d.CreationTimestamp.UTC(),
+	}
+	for _, c := range d.Status.Conditions {
+		summary.Conditions = append(summary.Conditions, DeploymentCondition{
+			Type:               string(c.Type),
+			Status:             string(c.Status),
+			Reason:             c.Reason,
+			Message:            c.Message,
+			LastTransitionTime: c.LastTransitionTime.UTC(),
+		})
+	}
+	return summary
+}
+
+// NewDeploymentDetail builds a DeploymentDetail from a Deployment and the ReplicaSets it owns.
+// ReplicaSets are ordered from the newest to the oldest.
+func NewDeploymentDetail(d *appsv1.Deployment, replicaSets []*appsv1.ReplicaSet) DeploymentDetail {
+	meta := *d.ObjectMeta.DeepCopy()
+	meta.ManagedFields = nil
+	detail := DeploymentDetail{
+		Metadata:    meta,
+		Spec:        d.Spec,
+		Status:      d.Status,
+		ReplicaSets: make([]ReplicaSetSummary, 0, len(replicaSets)),
+	}
+	for _, rs := range replicaSets {
+		desired := int32(1)
+		if rs.Spec.Replicas != nil {
+			desired = *rs.Spec.Replicas
+		}
+		images := []string{}
+		for _, c := range rs.Spec.Template.Spec.Containers {
+			images = append(images, c.Image)
+		}
+		detail.ReplicaSets = append(detail.ReplicaSets, ReplicaSetSummary{
+			Name:              rs.Name,
+			Revision:          rs.Annotations[revisionAnnotation],
+			DesiredReplicas:   desired,
+			ReadyReplicas:     rs.Status.ReadyReplicas,
+			AvailableReplicas: rs.Status.AvailableReplicas,
+			Images:            images,
+			CreationTimestamp: rs.CreationTimestamp.UTC(),
+		})
+	}
+	sort.SliceStable(detail.ReplicaSets, func(i, j int) bool {
+		return detail.ReplicaSets[i].CreationTimestamp.After(detail.ReplicaSets[j].CreationTimestamp)
+	})
+	return detail
+}
+
+// deploymentImages returns the images of all init and regular containers in the pod template.
+func deploymentImages(d *appsv1.Deployment) []string {
+	images := []string{}
+	for _, containers := range [][]corev1.Container{d.Spec.Template.Spec.InitContainers, d.Spec.Template.Spec.Containers} {
+		for _, c := range containers {
+			images = append(images, c.Image)
+		}
+	}
+	return images
+}
@@ -0,0 +1,111 @@
+package informer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	testutil "github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
+)
+
+func TestNewDeploymentSummary(t *testing.T) {
+	created := metav1.NewTime(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "web",
+			Namespace:         "shop",
+			Labels:            map[string]string{"team": "payments"},
+			CreationTimestamp: created,
+		},
+		Spec: testutil.NewDeploymentSpec(4, map[string]string{"app": "web"}, "nginx:1.27"),
+		Status: appsv1.DeploymentStatus{
+			ReadyReplicas:     3,
+			AvailableReplicas: 2,
+			UpdatedReplicas:   4,
+			Conditions: []appsv1.DeploymentCondition{{
+				Type:    appsv1.DeploymentProgressing,
+				Status:  corev1.ConditionTrue,
+				Reason:  "NewReplicaSetAvailable",
+				Message: "rollout complete",
+			}},
+		},
+	}
+	dep.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "init", Image: "busybox"}}
+
+	s := NewDeploymentSummary(dep)
+
+	require.Equal(t, "shop", s.Namespace)
+	require.Equal(t, "web", s.Name)
+	require.Equal(t, int32(4), s.DesiredReplicas)
+	require.Equal(t, int32(3), s.ReadyReplicas)
+	require.Equal(t, int32(2), s.AvailableReplicas)
+	require.Equal(t, int32(4), s.UpdatedReplicas)
+	require.Equal(t, []string{"busybox", "nginx:1.27"}, s.Images)
+	require.Equal(t, map[string]string{"team": "payments"}, s.Labels)
+	require.Equal(t, created.UTC(), s.CreationTimestamp)
+	require.Len(t, s.Conditions, 1)
+	require.Equal(t, "Progressing", s.Conditions[0].Type)
+	require.Equal(t, "True", s.Conditions[0].Status)
+	require.Equal(t, "NewReplicaSetAvailable", s.Conditions[0].Reason)
+}
+
+func TestNewDeploymentSummary_DefaultReplicas(t *testing.T) {
+	dep := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	s := NewDeploymentSummary(dep)
+
+	require.Equal(t, int32(1), s.DesiredReplicas)
+	require.NotNil(t, s.Images)
+	require.Empty(t, s.Images)
+}
+
+func TestNewDeploymentDetail(t *testing.T) {
+	older := metav1.NewTime(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	newer := metav1.NewTime(older.Add(time.Hour))
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "web",
+			Namespace:     "default",
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec: testutil.NewDeploymentSpec(2, map[string]string{"app": "web"}, "nginx:1.27"),
+	}
+	replicaSets := []*appsv1.ReplicaSet{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "web-old",
+				CreationTimestamp: older,
+				Annotations:       map[string]string{revisionAnnotation: "1"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "web-new",
+				CreationTimestamp: newer,
+				Annotations:       map[string]string{revisionAnnotation: "2"},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: dep.Spec.Replicas,
+				Template: dep.Spec.Template,
+			},
+			Status: appsv1.ReplicaSetStatus{ReadyReplicas: 2, AvailableReplicas: 2},
+		},
+	}
+
+	d := NewDeploymentDetail(dep, replicaSets)
+
+	require.Equal(t, "web", d.Metadata.Name)
+	require.Nil(t, d.Metadata.ManagedFields)
+	require.NotNil(t, dep.ManagedFields, "source object must not be modified")
+	require.Len(t, d.ReplicaSets, 2)
+	require.Equal(t, "web-new", d.ReplicaSets[0].Name)
+	require.Equal(t, "2", d.ReplicaSets[0].Revision)
+	require.Equal(t, int32(2), d.ReplicaSets[0].DesiredReplicas)
+	require.Equal(t, []string{"nginx:1.27"}, d.ReplicaSets[0].Images)
+	require.Equal(t, "web-old", d.ReplicaSets[1].Name)
+	require.Equal(t, int32(1), d.ReplicaSets[1].DesiredReplicas)
+}
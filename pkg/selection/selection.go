@@ -0,0 +1,77 @@
+// Package selection decides which Deployments the controller and the informer
+// act on, so that both share one view of the cluster.
+package selection
+
+import (
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// IgnoreAnnotation set to "true" opts a Deployment out of the controller and the informer.
+const IgnoreAnnotation = "tutorial.io/ignore"
+
+// Config selects Deployments by namespace, labels and IgnoreAnnotation.
+// The zero value selects every Deployment that has not opted out.
+type Config struct {
+	// Namespaces to include. Empty, or containing metav1.NamespaceAll, includes all namespaces.
+	Namespaces []string
+	// ExcludedNamespaces are never selected, even when listed in Namespaces.
+	ExcludedNamespaces []string
+	// Selector matches the labels of the Deployment. Nil selects every Deployment.
+	Selector labels.Selector
+}
+
+// IncludesNamespace reports whether Deployments in the namespace can be selected.
+func (c Config) IncludesNamespace(namespace string) bool {
+	if slices.Contains(c.ExcludedNamespaces, namespace) {
+		return false
+	}
+	return len(c.Namespaces) == 0 || slices.Contains(c.Namespaces, metav1.NamespaceAll) || slices.Contains(c.Namespaces, namespace)
+}
+
+// Matches reports whether the object is selected.
+func (c Config) Matches(obj metav1.Object) bool {
+	if !c.IncludesNamespace(obj.GetNamespace()) || Ignored(obj) {
+		return false
+	}
+	return c.Selector == nil || c.Selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// LabelSelector returns the selector to list Deployments with, so that the
+// API server only sends matching ones. It selects everything when Selector is nil.
+func (c Config) LabelSelector() labels.Selector {
+	if c.Selector == nil {
+		return labels.Everything()
+	}
+	return c.Selector
+}
+
+// FieldSelector returns a selector leaving out the excluded namespaces. The
+// API server supports it for every resource, so it can be used to list
+// Deployments and the objects related to them alike.
+func (c Config) FieldSelector() fields.Selector {
+	var terms []fields.Selector
+	for _, ns := range c.ExcludedNamespaces {
+		terms = append(terms, fields.OneTermNotEqualSelector("metadata.namespace", ns))
+	}
+	if len(terms) == 0 {
+		return fields.Everything()
+	}
+	return fields.AndSelectors(terms...)
+}
+
+// Ignored reports whether the object opted out with IgnoreAnnotation.
+func Ignored(obj metav1.Object) bool {
+	return obj.GetAnnotations()[IgnoreAnnotation] == "true"
+}
+
+// Predicate filters controller events to the selected objects. Updates are
+// judged by the new version, so opting out takes effect immediately.
+func (c Config) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool { return c.Matches(obj) })
+}
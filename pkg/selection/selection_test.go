@@ -0,0 +1,67 @@
+package selection
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func deployment(namespace string, labels, annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Labels: labels, Annotations: annotations}}
+}
+
+func TestConfig_Matches(t *testing.T) {
+	selector, err := labels.Parse("tier=frontend")
+	require.NoError(t, err)
+	frontend := map[string]string{"tier": "frontend"}
+	ignored := map[string]string{IgnoreAnnotation: "true"}
+
+	tests := []struct {
+		name   string
+		config Config
+		obj    *appsv1.Deployment
+		want   bool
+	}{
+		{"zero value selects everything", Config{}, deployment("team-a", nil, nil), true},
+		{"all namespaces", Config{Namespaces: []string{metav1.NamespaceAll}}, deployment("team-a", nil, nil), true},
+		{"included namespace", Config{Namespaces: []string{"team-a", "team-b"}}, deployment("team-b", nil, nil), true},
+		{"other namespace", Config{Namespaces: []string{"team-a"}}, deployment("team-b", nil, nil), false},
+		{"excluded wins over included", Config{Namespaces: []string{"team-a"}, ExcludedNamespaces: []string{"team-a"}}, deployment("team-a", nil, nil), false},
+		{"matching labels", Config{Selector: selector}, deployment("team-a", frontend, nil), true},
+		{"other labels", Config{Selector: selector}, deployment("team-a", map[string]string{"tier": "backend"}, nil), false},
+		{"opted out", Config{}, deployment("team-a", frontend, ignored), false},
+		{"opt-out needs true", Config{}, deployment("team-a", nil, map[string]string{IgnoreAnnotation: "false"}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.config.Matches(tt.obj))
+		})
+	}
+}
+
+func TestConfig_Predicate(t *testing.T) {
+	p := Config{ExcludedNamespaces: []string{"kube-system"}}.Predicate()
+
+	require.True(t, p.Create(event.CreateEvent{Object: deployment("team-a", nil, nil)}))
+	require.False(t, p.Create(event.CreateEvent{Object: deployment("kube-system", nil, nil)}))
+	require.False(t, p.Update(event.UpdateEvent{
+		ObjectOld: deployment("team-a", nil, nil),
+		ObjectNew: deployment("team-a", nil, map[string]string{IgnoreAnnotation: "true"}),
+	}))
+	require.True(t, p.Delete(event.DeleteEvent{Object: deployment("team-a", nil, nil)}))
+}
+
+func TestConfig_ServerSideSelectors(t *testing.T) {
+	require.True(t, Config{}.LabelSelector().Empty())
+	require.True(t, Config{}.FieldSelector().Empty())
+
+	selector, err := labels.Parse("tier=frontend")
+	require.NoError(t, err)
+	c := Config{Selector: selector, ExcludedNamespaces: []string{"kube-system", "kube-public"}}
+	require.Equal(t, "tier=frontend", c.LabelSelector().String())
+	require.Equal(t, "metadata.namespace!=kube-system,metadata.namespace!=kube-public", c.FieldSelector().String())
+}
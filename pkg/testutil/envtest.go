@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	goruntime "runtime"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -17,12 +20,37 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
-// SetupEnv starts envtest, creates a clientset, populates the cluster with sample Deployments, and returns env, clientset, and cleanup.
+// EnvOption customizes the envtest environment before it is started.
+type EnvOption func(*envtest.Environment)
+
+// WithValidatingWebhooks installs the webhook configurations and points them at
+// a local webhook server. Serve it on env.WebhookInstallOptions.LocalServingHost,
+// LocalServingPort and LocalServingCertDir.
+func WithValidatingWebhooks(configs ...*admissionregistrationv1.ValidatingWebhookConfiguration) EnvOption {
+	return func(env *envtest.Environment) {
+		env.WebhookInstallOptions.ValidatingWebhooks = append(env.WebhookInstallOptions.ValidatingWebhooks, configs...)
+	}
+}
+
+// WithMutatingWebhooks is WithValidatingWebhooks for mutating webhook configurations.
+func WithMutatingWebhooks(configs ...*admissionregistrationv1.MutatingWebhookConfiguration) EnvOption {
+	return func(env *envtest.Environment) {
+		env.WebhookInstallOptions.MutatingWebhooks = append(env.WebhookInstallOptions.MutatingWebhooks, configs...)
+	}
+}
+
+// SetupEnv starts envtest with the project's CRDs installed, creates a clientset, populates the cluster with sample Deployments, and returns env, clientset, and cleanup.
 // It also creates necessary resources for testing metrics and leader election.
-func SetupEnv(t *testing.T) (*envtest.Environment, *kubernetes.Clientset, func()) {
+func SetupEnv(t *testing.T, opts ...EnvOption) (*envtest.Environment, *kubernetes.Clientset, func()) {
 	t.Helper()
 	ctx := context.Background()
-	env := &envtest.Environment{}
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{CRDDirectory()},
+		ErrorIfCRDPathMissing: true,
+	}
+	for _, opt := range opts {
+		opt(env)
+	}
 
 	cfg, err := env.Start()
 	require.NoError(t, err)
@@ -100,6 +128,12 @@ func SetupEnv(t *testing.T) (*envtest.Environment, *kubernetes.Clientset, func()
 	return env, clientset, cleanup
 }
 
+// CRDDirectory returns the path of the CRD manifests shipped with the Helm chart.
+func CRDDirectory() string {
+	_, file, _, _ := goruntime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "chart", "app", "crds")
+}
+
 // NewObjectMeta creates a new ObjectMeta with the given name and namespace.
 func NewObjectMeta(name, namespace string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
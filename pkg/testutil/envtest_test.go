@@ -6,8 +6,10 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
 func TestInt32Ptr(t *testing.T) {
@@ -67,3 +69,21 @@ func TestSetupEnv(t *testing.T) {
 	require.Equal(t, "test-leader-election", configMap.Name)
 	require.Equal(t, "default", configMap.Namespace)
 }
+
+func TestWithValidatingWebhooks(t *testing.T) {
+	env := &envtest.Environment{}
+	config := &admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+
+	WithValidatingWebhooks(config)(env)
+
+	require.Equal(t, []*admissionregistrationv1.ValidatingWebhookConfiguration{config}, env.WebhookInstallOptions.ValidatingWebhooks)
+}
+
+func TestWithMutatingWebhooks(t *testing.T) {
+	env := &envtest.Environment{}
+	config := &admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+
+	WithMutatingWebhooks(config)(env)
+
+	require.Equal(t, []*admissionregistrationv1.MutatingWebhookConfiguration{config}, env.WebhookInstallOptions.MutatingWebhooks)
+}
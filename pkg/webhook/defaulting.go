@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// MutatePath is where the Deployment mutating webhook is served.
+const MutatePath = "/mutate-apps-v1-deployment"
+
+const (
+	// NameLabel is defaulted to the Deployment name.
+	NameLabel = "app.kubernetes.io/name"
+	// PrometheusScrapeAnnotation is defaulted to "true" on the pod template.
+	PrometheusScrapeAnnotation = "prometheus.io/scrape"
+)
+
+// DefaultingConfig configures the Deployment mutating webhook. Every default
+// is only applied when the Deployment does not set the field itself.
+type DefaultingConfig struct {
+	// Requests are the resource requests of containers without one. A resource
+	// with a limit is skipped, since the API server defaults its request to the limit.
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	// Labels are added to the Deployment and its pod template, in addition to NameLabel.
+	Labels map[string]string `json:"labels,omitempty"`
+	// PrometheusScrape sets PrometheusScrapeAnnotation on the pod template.
+	PrometheusScrape bool `json:"prometheusScrape"`
+	// TopologySpread is added to pod templates without topology spread constraints.
+	TopologySpread *TopologySpread `json:"topologySpread,omitempty"`
+	// ExcludedNamespaces are never mutated.
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+}
+
+// TopologySpread spreads the pods of a Deployment, selected by the Deployment's selector.
+type TopologySpread struct {
+	TopologyKey       string                               `json:"topologyKey"`
+	MaxSkew           int32                                `json:"maxSkew"`
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable"`
+}
+
+// DefaultDefaultingConfig requests 100m CPU and 128Mi memory, enables scraping
+// and spreads pods across zones on a best-effort basis.
+func DefaultDefaultingConfig() DefaultingConfig {
+	return DefaultingConfig{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+		PrometheusScrape: true,
+		TopologySpread: &TopologySpread{
+			TopologyKey:       corev1.LabelTopologyZone,
+			MaxSkew:           1,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+		},
+		ExcludedNamespaces: []string{metav1.NamespaceSystem},
+	}
+}
+
+// LoadDefaultingConfig reads the defaults from a YAML file. Fields missing from
+// the file keep their DefaultDefaultingConfig value; unknown fields are rejected.
+// Set topologySpread to null to disable it.
+func LoadDefaultingConfig(path string) (DefaultingConfig, error) {
+	config := DefaultDefaultingConfig()
+	// Decoding into the default map would merge the requests instead of replacing them.
+	defaultRequests := config.Requests
+	config.Requests = nil
+	if err := loadYAML(path, "defaulting config", &config); err != nil {
+		return config, err
+	}
+	if config.Requests == nil {
+		config.Requests = defaultRequests
+	}
+	if spread := config.TopologySpread; spread != nil {
+		if spread.TopologyKey == "" || spread.MaxSkew < 1 {
+			return config, fmt.Errorf("invalid topologySpread in %s: topologyKey is required and maxSkew must be at least 1", path)
+		}
+		switch spread.WhenUnsatisfiable {
+		case "":
+			spread.WhenUnsatisfiable = corev1.ScheduleAnyway
+		case corev1.ScheduleAnyway, corev1.DoNotSchedule:
+		default:
+			return config, fmt.Errorf("invalid topologySpread in %s: whenUnsatisfiable must be %q or %q", path, corev1.ScheduleAnyway, corev1.DoNotSchedule)
+		}
+	}
+	return config, nil
+}
+
+// DeploymentDefaulter applies a DefaultingConfig to Deployments on create and update.
+type DeploymentDefaulter struct {
+	Config DefaultingConfig
+}
+
+var _ admission.CustomDefaulter = &DeploymentDefaulter{}
+
+// SetupDeploymentDefaulter registers the mutating webhook with the manager's webhook server.
+func SetupDeploymentDefaulter(mgr manager.Manager, config DefaultingConfig) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&appsv1.Deployment{}).
+		WithDefaulter(&DeploymentDefaulter{Config: config}).
+		Complete()
+}
+
+// Default sets the missing defaults. The pod template is only defaulted on
+// create and by updates that change it, since defaulting it on any other update,
+// e.g. an annotation patch, would start an unplanned rollout.
+func (d *DeploymentDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return fmt.Errorf("expected a Deployment but got %T", obj)
+	}
+	if slices.Contains(d.Config.ExcludedNamespaces, deployment.Namespace) {
+		return nil
+	}
+	template, err := defaultsTemplate(ctx, deployment)
+	if err != nil {
+		return err
+	}
+	if applied := d.Config.apply(deployment, template); len(applied) > 0 {
+		log.Debug().Strs("defaults", applied).Msgf("Defaulted Deployment %s/%s", deployment.Namespace, deployment.Name)
+	}
+	return nil
+}
+
+// defaultsTemplate reports whether the admission request may default the pod
+// template: it is a create, or an update that changes the template. Without a
+// request in the context, as when called directly, the template is defaulted.
+func defaultsTemplate(ctx context.Context, deployment *appsv1.Deployment) (bool, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil || req.Operation != admissionv1.Update {
+		return true, nil
+	}
+	old := &appsv1.Deployment{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return false, fmt.Errorf("failed to decode the old Deployment: %w", err)
+	}
+	return !equality.Semantic.DeepEqual(old.Spec.Template, deployment.Spec.Template), nil
+}
+
+// Apply sets the missing defaults on the Deployment and returns the paths of the fields it set.
+func (c DefaultingConfig) Apply(deployment *appsv1.Deployment) []string {
+	return c.apply(deployment, true)
+}
+
+// apply sets the missing defaults, leaving the pod template alone unless defaultTemplate is set.
+func (c DefaultingConfig) apply(deployment *appsv1.Deployment, defaultTemplate bool) []string {
+	var applied []string
+	template := &deployment.Spec.Template
+
+	// The selector may share its map with the template labels, so never write to them in place.
+	deployment.Labels = maps.Clone(deployment.Labels)
+	template.Labels = maps.Clone(template.Labels)
+	labels := map[string]string{NameLabel: deployment.Name}
+	maps.Copy(labels, c.Labels)
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		if setDefault(&deployment.Labels, key, labels[key]) {
+			applied = append(applied, fmt.Sprintf("metadata.labels[%s]", key))
+		}
+		if defaultTemplate && setDefault(&template.Labels, key, labels[key]) {
+			applied = append(applied, fmt.Sprintf("spec.template.metadata.labels[%s]", key))
+		}
+	}
+	if !defaultTemplate {
+		return applied
+	}
+
+	if c.PrometheusScrape && setDefault(&template.Annotations, PrometheusScrapeAnnotation, "true") {
+		applied = append(applied, fmt.Sprintf("spec.template.metadata.annotations[%s]", PrometheusScrapeAnnotation))
+	}
+
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		for _, name := range slices.Sorted(maps.Keys(c.Requests)) {
+			if _, ok := container.Resources.Requests[name]; ok {
+				continue
+			}
+			if _, ok := container.Resources.Limits[name]; ok {
+				continue
+			}
+			if container.Resources.Requests == nil {
+				container.Resources.Requests = corev1.ResourceList{}
+			}
+			container.Resources.Requests[name] = c.Requests[name].DeepCopy()
+			applied = append(applied, fmt.Sprintf("spec.template.spec.containers[%d].resources.requests[%s]", i, name))
+		}
+	}
+
+	if c.TopologySpread != nil && len(template.Spec.TopologySpreadConstraints) == 0 && deployment.Spec.Selector != nil {
+		template.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+			MaxSkew:           c.TopologySpread.MaxSkew,
+			TopologyKey:       c.TopologySpread.TopologyKey,
+			WhenUnsatisfiable: c.TopologySpread.WhenUnsatisfiable,
+			LabelSelector:     deployment.Spec.Selector.DeepCopy(),
+		}}
+		applied = append(applied, "spec.template.spec.topologySpreadConstraints")
+	}
+	return applied
+}
+
+// setDefault sets key in the map unless it is already present and reports whether it did.
+func setDefault(m *map[string]string, key, value string) bool {
+	if _, ok := (*m)[key]; ok {
+		return false
+	}
+	if *m == nil {
+		*m = map[string]string{}
+	}
+	(*m)[key] = value
+	return true
+}
+
+// NewMutatingWebhookConfiguration registers MutatePath of the given Service
+// for Deployment creates and updates. caBundle must contain the CA of the serving certificate.
+func NewMutatingWebhookConfiguration(name, serviceNamespace, serviceName string, caBundle []byte, failurePolicy admissionregistrationv1.FailurePolicyType) *admissionregistrationv1.MutatingWebhookConfiguration {
+	path := MutatePath
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	return &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{{
+			Name: "mdeployment.tutorial.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service:  &admissionregistrationv1.ServiceReference{Namespace: serviceNamespace, Name: serviceName, Path: &path},
+				CABundle: caBundle,
+			},
+			Rules:                   []admissionregistrationv1.RuleWithOperations{deploymentRule()},
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	}
+}
@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
+)
+
+func TestDefaultingConfig_Apply(t *testing.T) {
+	config := DefaultDefaultingConfig()
+	config.Labels = map[string]string{"app.kubernetes.io/part-of": "shop"}
+	d := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	d.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}
+
+	applied := config.Apply(d)
+
+	require.Equal(t, []string{
+		"metadata.labels[app.kubernetes.io/name]",
+		"spec.template.metadata.labels[app.kubernetes.io/name]",
+		"metadata.labels[app.kubernetes.io/part-of]",
+		"spec.template.metadata.labels[app.kubernetes.io/part-of]",
+		"spec.template.metadata.annotations[prometheus.io/scrape]",
+		"spec.template.spec.containers[0].resources.requests[cpu]",
+		"spec.template.spec.topologySpreadConstraints",
+	}, applied)
+	require.Equal(t, map[string]string{NameLabel: "web", "app.kubernetes.io/part-of": "shop"}, d.Labels)
+	require.Equal(t, map[string]string{"app": "web", NameLabel: "web", "app.kubernetes.io/part-of": "shop"}, d.Spec.Template.Labels)
+	require.Equal(t, "true", d.Spec.Template.Annotations[PrometheusScrapeAnnotation])
+	require.Equal(t, corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		d.Spec.Template.Spec.Containers[0].Resources.Requests, "memory is left to default to its limit")
+	require.Equal(t, []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       corev1.LabelTopologyZone,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}}, d.Spec.Template.Spec.TopologySpreadConstraints)
+
+	require.Empty(t, config.Apply(d), "defaults are applied once")
+}
+
+func TestDefaultingConfig_ApplyKeepsExistingValues(t *testing.T) {
+	d := compliantDeployment("web")
+	d.Labels[NameLabel] = "shop"
+	d.Spec.Template.Labels[NameLabel] = "shop"
+	d.Spec.Template.Annotations = map[string]string{PrometheusScrapeAnnotation: "false"}
+	spread := []corev1.TopologySpreadConstraint{{MaxSkew: 2, TopologyKey: corev1.LabelHostname, WhenUnsatisfiable: corev1.DoNotSchedule}}
+	d.Spec.Template.Spec.TopologySpreadConstraints = spread
+	want := d.DeepCopy()
+
+	require.Empty(t, DefaultDefaultingConfig().Apply(d))
+	require.Equal(t, want, d)
+}
+
+func TestDeploymentDefaulter_Default(t *testing.T) {
+	defaulter := &DeploymentDefaulter{Config: DefaultDefaultingConfig()}
+
+	d := &appsv1.Deployment{ObjectMeta: testutil.NewObjectMeta("coredns", metav1.NamespaceSystem)}
+	require.NoError(t, defaulter.Default(context.Background(), d))
+	require.Empty(t, d.Labels, "excluded namespaces are not mutated")
+
+	d.Namespace = "default"
+	require.NoError(t, defaulter.Default(context.Background(), d))
+	require.Equal(t, "coredns", d.Labels[NameLabel])
+
+	require.Error(t, defaulter.Default(context.Background(), &corev1.Pod{}))
+}
+
+func TestDeploymentDefaulter_Update(t *testing.T) {
+	defaulter := &DeploymentDefaulter{Config: DefaultDefaultingConfig()}
+	// An existing Deployment, created before the webhook was enabled.
+	old := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta("web", "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": "web"}, "nginx"),
+	}
+	raw, err := json.Marshal(old)
+	require.NoError(t, err)
+	ctx := admission.NewContextWithRequest(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Update,
+		OldObject: runtime.RawExtension{Raw: raw},
+	}})
+
+	d := old.DeepCopy()
+	d.Annotations = map[string]string{"tutorial.io/config-hash": "abc"}
+	require.NoError(t, defaulter.Default(ctx, d))
+	require.Equal(t, old.Spec.Template, d.Spec.Template, "a metadata-only update does not change the pod template")
+	require.Equal(t, "web", d.Labels[NameLabel])
+
+	d = old.DeepCopy()
+	d.Spec.Template.Spec.Containers[0].Image = "nginx:1.27"
+	require.NoError(t, defaulter.Default(ctx, d))
+	require.Equal(t, "web", d.Spec.Template.Labels[NameLabel], "an update of the pod template is defaulted")
+	require.NotEmpty(t, d.Spec.Template.Spec.Containers[0].Resources.Requests)
+}
+
+func TestLoadDefaultingConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	cfg, err := LoadDefaultingConfig(write("config.yaml", "requests:\n  cpu: 50m\nlabels:\n  team: platform\ntopologySpread:\n  topologyKey: kubernetes.io/hostname\n  maxSkew: 2\n"))
+	require.NoError(t, err)
+	want := DefaultDefaultingConfig()
+	want.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")}
+	want.Labels = map[string]string{"team": "platform"}
+	want.TopologySpread = &TopologySpread{TopologyKey: corev1.LabelHostname, MaxSkew: 2, WhenUnsatisfiable: corev1.ScheduleAnyway}
+	require.Equal(t, want, cfg)
+
+	cfg, err = LoadDefaultingConfig(write("disabled.yaml", "prometheusScrape: false\ntopologySpread: null\n"))
+	require.NoError(t, err)
+	require.False(t, cfg.PrometheusScrape)
+	require.Nil(t, cfg.TopologySpread)
+	require.Equal(t, DefaultDefaultingConfig().Requests, cfg.Requests)
+
+	_, err = LoadDefaultingConfig(write("skew.yaml", "topologySpread:\n  topologyKey: zone\n  maxSkew: 0\n"))
+	require.ErrorContains(t, err, "maxSkew must be at least 1")
+
+	_, err = LoadDefaultingConfig(write("action.yaml", "topologySpread:\n  topologyKey: zone\n  maxSkew: 1\n  whenUnsatisfiable: Never\n"))
+	require.ErrorContains(t, err, "whenUnsatisfiable must be")
+
+	_, err = LoadDefaultingConfig(write("unknown.yaml", "annotations: {}\n"))
+	require.ErrorContains(t, err, "unknown field")
+}
+
+func TestNewMutatingWebhookConfiguration(t *testing.T) {
+	config := NewMutatingWebhookConfiguration("mutate", "system", "webhook", []byte("ca"), admissionregistrationv1.Ignore)
+
+	require.Len(t, config.Webhooks, 1)
+	wh := config.Webhooks[0]
+	require.Equal(t, MutatePath, *wh.ClientConfig.Service.Path)
+	require.Equal(t, admissionregistrationv1.Ignore, *wh.FailurePolicy)
+	require.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, wh.Rules[0].Operations)
+}
+
+func TestDeploymentDefaulter_Envtest(t *testing.T) {
+	mutating := NewMutatingWebhookConfiguration("deployment-defaults", "default", "webhook", nil, admissionregistrationv1.Ignore)
+	validating := NewValidatingWebhookConfiguration("deployment-validation", "default", "webhook", nil, admissionregistrationv1.Ignore)
+	env, clientset, cleanup := testutil.SetupEnv(t, testutil.WithMutatingWebhooks(mutating), testutil.WithValidatingWebhooks(validating))
+	defer cleanup()
+
+	skipValidation := true
+	opts := env.WebhookInstallOptions
+	mgr, err := manager.New(env.Config, manager.Options{
+		Scheme:  scheme.Scheme,
+		Metrics: server.Options{BindAddress: "0"},
+		WebhookServer: crwebhook.NewServer(crwebhook.Options{
+			Host:    opts.LocalServingHost,
+			Port:    opts.LocalServingPort,
+			CertDir: opts.LocalServingCertDir,
+		}),
+		Controller: config.Controller{SkipNameValidation: &skipValidation},
+	})
+	require.NoError(t, err)
+	rules := DefaultValidationRules()
+	rules.Mode = ValidationEnforce
+	require.NoError(t, SetupDeploymentDefaulter(mgr, DefaultDefaultingConfig()))
+	require.NoError(t, SetupDeploymentValidator(mgr, rules))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = mgr.Start(ctx) }()
+
+	// Mutating webhooks run first, so the defaulted requests satisfy validation.
+	d := compliantDeployment("defaulted")
+	d.Spec.Template.Spec.Containers[0].Resources.Requests = nil
+	var created *appsv1.Deployment
+	require.Eventually(t, func() bool {
+		created, err = clientset.AppsV1().Deployments("default").Create(ctx, d, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		return err == nil && created.Labels[NameLabel] == "defaulted"
+	}, 10*time.Second, 250*time.Millisecond)
+	require.Equal(t, "true", created.Spec.Template.Annotations[PrometheusScrapeAnnotation])
+	require.Len(t, created.Spec.Template.Spec.TopologySpreadConstraints, 1)
+	require.Equal(t, resource.MustParse("100m"), created.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU])
+}
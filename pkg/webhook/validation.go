@@ -0,0 +1,236 @@
+// Package webhook implements the admission webhooks served by the controller manager.
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+// ValidatePath is where the Deployment validating webhook is served.
+const ValidatePath = "/validate-apps-v1-deployment"
+
+// ValidationMode selects whether violations reject a Deployment or only warn.
+type ValidationMode string
+
+const (
+	// ValidationEnforce rejects Deployments that violate the rules.
+	ValidationEnforce ValidationMode = "enforce"
+	// ValidationAudit admits every Deployment and returns violations as warnings.
+	ValidationAudit ValidationMode = "audit"
+)
+
+// ValidationRules configures the Deployment validating webhook.
+type ValidationRules struct {
+	Mode ValidationMode `json:"mode,omitempty"`
+	// RequiredLabels must be set on the Deployment.
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+	// RequireResourceRequests and RequireResourceLimits need CPU and memory set on every container.
+	RequireResourceRequests bool `json:"requireResourceRequests"`
+	RequireResourceLimits   bool `json:"requireResourceLimits"`
+	// RequireReadinessProbe and RequireLivenessProbe apply to every container, not to init containers.
+	RequireReadinessProbe bool `json:"requireReadinessProbe"`
+	RequireLivenessProbe  bool `json:"requireLivenessProbe"`
+	// ExcludedNamespaces are never validated.
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+}
+
+// DefaultValidationRules audits every rule with the team and owner labels required.
+func DefaultValidationRules() ValidationRules {
+	return ValidationRules{
+		Mode:                    ValidationAudit,
+		RequiredLabels:          []string{"team", "owner"},
+		RequireResourceRequests: true,
+		RequireResourceLimits:   true,
+		RequireReadinessProbe:   true,
+		RequireLivenessProbe:    true,
+		ExcludedNamespaces:      []string{metav1.NamespaceSystem},
+	}
+}
+
+// LoadValidationRules reads rules from a YAML file. Fields missing from the
+// file keep their DefaultValidationRules value; unknown fields are rejected.
+func LoadValidationRules(path string) (ValidationRules, error) {
+	rules := DefaultValidationRules()
+	if err := loadYAML(path, "validation rules", &rules); err != nil {
+		return rules, err
+	}
+	if rules.Mode != ValidationEnforce && rules.Mode != ValidationAudit {
+		return rules, fmt.Errorf("invalid validation mode %q: must be %q or %q", rules.Mode, ValidationEnforce, ValidationAudit)
+	}
+	return rules, nil
+}
+
+// loadYAML decodes the file over the defaults already in v.
+func loadYAML(path, what string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	if err := yaml.UnmarshalStrict(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s %s: %w", what, path, err)
+	}
+	return nil
+}
+
+// DeploymentValidator checks Deployments against ValidationRules on create and update.
+type DeploymentValidator struct {
+	Rules ValidationRules
+}
+
+var _ admission.CustomValidator = &DeploymentValidator{}
+
+// SetupDeploymentValidator registers the validating webhook with the manager's webhook server.
+func SetupDeploymentValidator(mgr manager.Manager, rules ValidationRules) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&appsv1.Deployment{}).
+		WithValidator(&DeploymentValidator{Rules: rules}).
+		Complete()
+}
+
+func (v *DeploymentValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(obj)
+}
+
+// ValidateUpdate only validates updates that change the checked fields. Other
+// updates, such as status and annotation patches, scaling and the removal of
+// finalizers during deletion, are admitted even when the Deployment already
+// violates the rules, so that it can still be scaled down and deleted.
+func (v *DeploymentValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldDeployment, ok := oldObj.(*appsv1.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a Deployment but got %T", oldObj)
+	}
+	newDeployment, ok := newObj.(*appsv1.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a Deployment but got %T", newObj)
+	}
+	if !newDeployment.DeletionTimestamp.IsZero() || !v.Rules.checkedFieldsChanged(oldDeployment, newDeployment) {
+		return nil, nil
+	}
+	return v.validate(newDeployment)
+}
+
+func (v *DeploymentValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *DeploymentValidator) validate(obj runtime.Object) (admission.Warnings, error) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a Deployment but got %T", obj)
+	}
+	if slices.Contains(v.Rules.ExcludedNamespaces, deployment.Namespace) {
+		return nil, nil
+	}
+	errs := v.Rules.Validate(deployment)
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	if v.Rules.Mode == ValidationAudit {
+		log.Warn().Msgf("Admitting Deployment %s/%s with %d rule violations in audit mode", deployment.Namespace, deployment.Name, len(errs))
+		warnings := make(admission.Warnings, 0, len(errs))
+		for _, err := range errs {
+			warnings = append(warnings, err.Error())
+		}
+		return warnings, nil
+	}
+	log.Info().Msgf("Rejecting Deployment %s/%s with %d rule violations", deployment.Namespace, deployment.Name, len(errs))
+	return nil, apierrors.NewInvalid(appsv1.SchemeGroupVersion.WithKind("Deployment").GroupKind(), deployment.Name, errs)
+}
+
+// Validate returns every rule the Deployment violates.
+func (r ValidationRules) Validate(deployment *appsv1.Deployment) field.ErrorList {
+	var errs field.ErrorList
+	labelsPath := field.NewPath("metadata", "labels")
+	for _, label := range r.RequiredLabels {
+		if deployment.Labels[label] == "" {
+			errs = append(errs, field.Required(labelsPath.Key(label), "label is required"))
+		}
+	}
+	containersPath := field.NewPath("spec", "template", "spec", "containers")
+	for i, c := range deployment.Spec.Template.Spec.Containers {
+		path := containersPath.Index(i)
+		resources := path.Child("resources")
+		if r.RequireResourceRequests {
+			errs = append(errs, requireResources(resources.Child("requests"), c.Resources.Requests)...)
+		}
+		if r.RequireResourceLimits {
+			errs = append(errs, requireResources(resources.Child("limits"), c.Resources.Limits)...)
+		}
+		if r.RequireReadinessProbe && c.ReadinessProbe == nil {
+			errs = append(errs, field.Required(path.Child("readinessProbe"), fmt.Sprintf("container %q needs a readiness probe", c.Name)))
+		}
+		if r.RequireLivenessProbe && c.LivenessProbe == nil {
+			errs = append(errs, field.Required(path.Child("livenessProbe"), fmt.Sprintf("container %q needs a liveness probe", c.Name)))
+		}
+	}
+	return errs
+}
+
+// checkedFieldsChanged reports whether an update changes the required labels or the pod template.
+func (r ValidationRules) checkedFieldsChanged(oldDeployment, newDeployment *appsv1.Deployment) bool {
+	for _, label := range r.RequiredLabels {
+		if oldDeployment.Labels[label] != newDeployment.Labels[label] {
+			return true
+		}
+	}
+	return !equality.Semantic.DeepEqual(oldDeployment.Spec.Template, newDeployment.Spec.Template)
+}
+
+func requireResources(path *field.Path, resources corev1.ResourceList) field.ErrorList {
+	var errs field.ErrorList
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if _, ok := resources[name]; !ok {
+			errs = append(errs, field.Required(path.Key(string(name)), ""))
+		}
+	}
+	return errs
+}
+
+// NewValidatingWebhookConfiguration registers ValidatePath of the given Service
+// for Deployment creates and updates. caBundle must contain the CA of the serving certificate.
+func NewValidatingWebhookConfiguration(name, serviceNamespace, serviceName string, caBundle []byte, failurePolicy admissionregistrationv1.FailurePolicyType) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	path := ValidatePath
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name: "vdeployment.tutorial.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service:  &admissionregistrationv1.ServiceReference{Namespace: serviceNamespace, Name: serviceName, Path: &path},
+				CABundle: caBundle,
+			},
+			Rules:                   []admissionregistrationv1.RuleWithOperations{deploymentRule()},
+			FailurePolicy:           &failurePolicy,
+			SideEffects:             &sideEffects,
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	}
+}
+
+func deploymentRule() admissionregistrationv1.RuleWithOperations {
+	return admissionregistrationv1.RuleWithOperations{
+		Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{appsv1.GroupName},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"deployments"},
+		},
+	}
+}
@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	crwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/MikeBorovik/k8s-controller-tutorial/pkg/testutil"
+)
+
+func compliantDeployment(name string) *appsv1.Deployment {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("64Mi"),
+	}
+	probe := &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz"}}}
+	d := &appsv1.Deployment{
+		ObjectMeta: testutil.NewObjectMeta(name, "default"),
+		Spec:       testutil.NewDeploymentSpec(1, map[string]string{"app": name}, "nginx"),
+	}
+	d.Labels = map[string]string{"team": "platform", "owner": "alice"}
+	c := &d.Spec.Template.Spec.Containers[0]
+	c.Resources = corev1.ResourceRequirements{Requests: resources, Limits: resources}
+	c.ReadinessProbe = probe
+	c.LivenessProbe = probe
+	return d
+}
+
+func TestValidationRules_Validate(t *testing.T) {
+	rules := DefaultValidationRules()
+
+	require.Empty(t, rules.Validate(compliantDeployment("web")))
+
+	d := compliantDeployment("web")
+	delete(d.Labels, "owner")
+	c := &d.Spec.Template.Spec.Containers[0]
+	delete(c.Resources.Limits, corev1.ResourceMemory)
+	c.Resources.Requests = nil
+	c.LivenessProbe = nil
+
+	var got []string
+	for _, err := range rules.Validate(d) {
+		got = append(got, err.Error())
+	}
+	require.Equal(t, []string{
+		"metadata.labels[owner]: Required value: label is required",
+		"spec.template.spec.containers[0].resources.requests[cpu]: Required value",
+		"spec.template.spec.containers[0].resources.requests[memory]: Required value",
+		"spec.template.spec.containers[0].resources.limits[memory]: Required value",
+		`spec.template.spec.containers[0].livenessProbe: Required value: container "container" needs a liveness probe`,
+	}, got)
+
+	require.Empty(t, ValidationRules{}.Validate(d), "disabled rules report nothing")
+}
+
+func TestDeploymentValidator_Modes(t *testing.T) {
+	ctx := context.Background()
+	d := compliantDeployment("web")
+	d.Labels = nil
+
+	enforce := &DeploymentValidator{Rules: DefaultValidationRules()}
+	enforce.Rules.Mode = ValidationEnforce
+	warnings, err := enforce.ValidateCreate(ctx, d)
+	require.True(t, apierrors.IsInvalid(err))
+	require.Contains(t, err.Error(), "metadata.labels[team]")
+	require.Empty(t, warnings)
+
+	_, err = enforce.ValidateUpdate(ctx, compliantDeployment("web"), d)
+	require.True(t, apierrors.IsInvalid(err))
+
+	warnings, err = enforce.ValidateDelete(ctx, d)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+
+	audit := &DeploymentValidator{Rules: DefaultValidationRules()}
+	warnings, err = audit.ValidateCreate(ctx, d)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"metadata.labels[team]: Required value: label is required",
+		"metadata.labels[owner]: Required value: label is required",
+	}, []string(warnings))
+
+	d.Namespace = metav1.NamespaceSystem
+	warnings, err = enforce.ValidateCreate(ctx, d)
+	require.NoError(t, err, "excluded namespaces are not validated")
+	require.Empty(t, warnings)
+}
+
+func TestDeploymentValidator_UpdatesOfViolatingDeployments(t *testing.T) {
+	ctx := context.Background()
+	enforce := &DeploymentValidator{Rules: DefaultValidationRules()}
+	enforce.Rules.Mode = ValidationEnforce
+	old := compliantDeployment("web")
+	old.Spec.Template.Spec.Containers[0].LivenessProbe = nil
+
+	t.Run("Unchanged template and labels", func(t *testing.T) {
+		d := old.DeepCopy()
+		d.Spec.Replicas = new(int32)
+		d.Annotations = map[string]string{"tutorial.io/image-policy-scaled-from": "1"}
+		warnings, err := enforce.ValidateUpdate(ctx, old, d)
+		require.NoError(t, err, "scaling and annotating a violating Deployment is admitted")
+		require.Empty(t, warnings)
+	})
+
+	t.Run("Deletion", func(t *testing.T) {
+		d := old.DeepCopy()
+		d.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		d.Finalizers = nil
+		d.Spec.Template.Spec.Containers[0].Image = "nginx:1.27"
+		_, err := enforce.ValidateUpdate(ctx, old, d)
+		require.NoError(t, err, "updates of a terminating Deployment are admitted")
+	})
+
+	t.Run("Changed template", func(t *testing.T) {
+		d := old.DeepCopy()
+		d.Spec.Template.Spec.Containers[0].Image = "nginx:1.27"
+		_, err := enforce.ValidateUpdate(ctx, old, d)
+		require.True(t, apierrors.IsInvalid(err))
+	})
+
+	t.Run("Changed required label", func(t *testing.T) {
+		d := old.DeepCopy()
+		d.Labels["owner"] = "bob"
+		_, err := enforce.ValidateUpdate(ctx, old, d)
+		require.True(t, apierrors.IsInvalid(err))
+	})
+}
+
+func TestLoadValidationRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	rules, err := LoadValidationRules(write("rules.yaml", "mode: enforce\nrequiredLabels: [team]\nrequireLivenessProbe: false\n"))
+	require.NoError(t, err)
+	want := DefaultValidationRules()
+	want.Mode = ValidationEnforce
+	want.RequiredLabels = []string{"team"}
+	want.RequireLivenessProbe = false
+	require.Equal(t, want, rules)
+
+	_, err = LoadValidationRules(write("mode.yaml", "mode: block\n"))
+	require.ErrorContains(t, err, `invalid validation mode "block"`)
+
+	_, err = LoadValidationRules(write("unknown.yaml", "requireLabels: [team]\n"))
+	require.ErrorContains(t, err, "unknown field")
+
+	_, err = LoadValidationRules(filepath.Join(dir, "missing.yaml"))
+	require.ErrorContains(t, err, "failed to read validation rules")
+}
+
+func TestNewValidatingWebhookConfiguration(t *testing.T) {
+	config := NewValidatingWebhookConfiguration("validate", "system", "webhook", []byte("ca"), admissionregistrationv1.Fail)
+
+	require.Equal(t, "validate", config.Name)
+	require.Len(t, config.Webhooks, 1)
+	wh := config.Webhooks[0]
+	require.Equal(t, ValidatePath, *wh.ClientConfig.Service.Path)
+	require.Equal(t, "system", wh.ClientConfig.Service.Namespace)
+	require.Equal(t, []byte("ca"), wh.ClientConfig.CABundle)
+	require.Equal(t, admissionregistrationv1.Fail, *wh.FailurePolicy)
+	require.Equal(t, []string{"deployments"}, wh.Rules[0].Resources)
+}
+
+func TestDeploymentValidator_Envtest(t *testing.T) {
+	// Ignore failures until the webhook server is up, so SetupEnv can create its sample Deployments.
+	webhookConfig := NewValidatingWebhookConfiguration("deployment-validation", "default", "webhook", nil, admissionregistrationv1.Ignore)
+	env, clientset, cleanup := testutil.SetupEnv(t, testutil.WithValidatingWebhooks(webhookConfig))
+	defer cleanup()
+
+	skipValidation := true
+	opts := env.WebhookInstallOptions
+	mgr, err := manager.New(env.Config, manager.Options{
+		Scheme:  scheme.Scheme,
+		Metrics: server.Options{BindAddress: "0"},
+		WebhookServer: crwebhook.NewServer(crwebhook.Options{
+			Host:    opts.LocalServingHost,
+			Port:    opts.LocalServingPort,
+			CertDir: opts.LocalServingCertDir,
+		}),
+		Controller: config.Controller{SkipNameValidation: &skipValidation},
+	})
+	require.NoError(t, err)
+	rules := DefaultValidationRules()
+	rules.Mode = ValidationEnforce
+	require.NoError(t, SetupDeploymentValidator(mgr, rules))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = mgr.Start(ctx) }()
+
+	deployments := clientset.AppsV1().Deployments("default")
+	require.Eventually(t, func() bool {
+		invalid := compliantDeployment("invalid")
+		invalid.Labels = nil
+		_, err := deployments.Create(ctx, invalid, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		return apierrors.IsInvalid(err)
+	}, 10*time.Second, 250*time.Millisecond)
+
+	_, err = deployments.Create(ctx, compliantDeployment("valid"), metav1.CreateOptions{})
+	require.NoError(t, err)
+}